@@ -0,0 +1,160 @@
+package observability
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Canonical attribute keys shared by logs, spans and metrics
+const (
+	TenantIDKey      = attribute.Key("tenant.id")
+	UserIDKey        = attribute.Key("user.id")
+	RequestIDKey     = attribute.Key("request.id")
+	SessionIDKey     = attribute.Key("session.id")
+	CorrelationIDKey = attribute.Key("correlation.id")
+	ComponentKey     = attribute.Key("component")
+)
+
+// DeploymentSlotKey holds the blue-green deployment slot on resources and log entries
+const DeploymentSlotKey = attribute.Key("deployment.slot")
+
+// deploymentSlotAttributes returns the deployment slot attribute, or none if no slot is configured
+func deploymentSlotAttributes(slot string) []attribute.KeyValue {
+	if slot = resolveDeploymentSlot(slot); slot != "" {
+		return []attribute.KeyValue{DeploymentSlotKey.String(slot)}
+	}
+	return nil
+}
+
+// TenantID returns the canonical tenant ID attribute
+func TenantID(v string) attribute.KeyValue {
+	return TenantIDKey.String(v)
+}
+
+// TenantIDField returns the canonical tenant ID log field
+func TenantIDField(v string) zap.Field {
+	return ZapField(TenantID(v))
+}
+
+// UserID returns the canonical user ID attribute
+func UserID(v string) attribute.KeyValue {
+	return UserIDKey.String(v)
+}
+
+// UserIDField returns the canonical user ID log field
+func UserIDField(v string) zap.Field {
+	return ZapField(UserID(v))
+}
+
+// RequestID returns the canonical request ID attribute
+func RequestID(v string) attribute.KeyValue {
+	return RequestIDKey.String(v)
+}
+
+// RequestIDField returns the canonical request ID log field
+func RequestIDField(v string) zap.Field {
+	return ZapField(RequestID(v))
+}
+
+// SessionID returns the canonical session ID attribute
+func SessionID(v string) attribute.KeyValue {
+	return SessionIDKey.String(v)
+}
+
+// SessionIDField returns the canonical session ID log field
+func SessionIDField(v string) zap.Field {
+	return ZapField(SessionID(v))
+}
+
+// CorrelationID returns the canonical correlation ID attribute
+func CorrelationID(v string) attribute.KeyValue {
+	return CorrelationIDKey.String(v)
+}
+
+// CorrelationIDField returns the canonical correlation ID log field
+func CorrelationIDField(v string) zap.Field {
+	return ZapField(CorrelationID(v))
+}
+
+// Component returns the canonical component attribute
+func Component(v string) attribute.KeyValue {
+	return ComponentKey.String(v)
+}
+
+// ComponentField returns the canonical component log field
+func ComponentField(v string) zap.Field {
+	return ZapField(Component(v))
+}
+
+// ZapField converts an OpenTelemetry attribute into a zap field with the same key
+func ZapField(kv attribute.KeyValue) zap.Field {
+	key := string(kv.Key)
+	switch kv.Value.Type() {
+	case attribute.BOOL:
+		return zap.Bool(key, kv.Value.AsBool())
+	case attribute.INT64:
+		return zap.Int64(key, kv.Value.AsInt64())
+	case attribute.FLOAT64:
+		return zap.Float64(key, kv.Value.AsFloat64())
+	case attribute.STRING:
+		return zap.String(key, kv.Value.AsString())
+	case attribute.BOOLSLICE:
+		return zap.Bools(key, kv.Value.AsBoolSlice())
+	case attribute.INT64SLICE:
+		return zap.Int64s(key, kv.Value.AsInt64Slice())
+	case attribute.FLOAT64SLICE:
+		return zap.Float64s(key, kv.Value.AsFloat64Slice())
+	case attribute.STRINGSLICE:
+		return zap.Strings(key, kv.Value.AsStringSlice())
+	default:
+		return zap.String(key, kv.Value.Emit())
+	}
+}
+
+// attributesFromFields converts zap fields into OpenTelemetry attributes with the same keys
+func attributesFromFields(fields []zap.Field) []attribute.KeyValue {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	keys := make([]string, 0, len(enc.Fields))
+	for key := range enc.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		switch v := enc.Fields[key].(type) {
+		case string:
+			attrs = append(attrs, attribute.String(key, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(key, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(key, v))
+		case int32:
+			attrs = append(attrs, attribute.Int64(key, int64(v)))
+		case uint64:
+			attrs = append(attrs, attribute.Int64(key, int64(v)))
+		case uint32:
+			attrs = append(attrs, attribute.Int64(key, int64(v)))
+		case float64:
+			attrs = append(attrs, attribute.Float64(key, v))
+		case float32:
+			attrs = append(attrs, attribute.Float64(key, float64(v)))
+		case time.Duration:
+			attrs = append(attrs, attribute.String(key, v.String()))
+		case time.Time:
+			attrs = append(attrs, attribute.String(key, v.Format(time.RFC3339Nano)))
+		default:
+			attrs = append(attrs, attribute.String(key, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
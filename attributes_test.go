@@ -0,0 +1,52 @@
+package observability
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+func TestCanonicalKeysMatchAcrossSignals(t *testing.T) {
+	tests := []struct {
+		attr  attribute.KeyValue
+		field zap.Field
+		key   string
+	}{
+		{TenantID("t1"), TenantIDField("t1"), "tenant.id"},
+		{UserID("u1"), UserIDField("u1"), "user.id"},
+		{RequestID("r1"), RequestIDField("r1"), "request.id"},
+		{SessionID("s1"), SessionIDField("s1"), "session.id"},
+		{CorrelationID("c1"), CorrelationIDField("c1"), "correlation.id"},
+		{Component("c"), ComponentField("c"), "component"},
+	}
+	for _, tt := range tests {
+		if string(tt.attr.Key) != tt.key {
+			t.Errorf("attribute key = %q, want %q", tt.attr.Key, tt.key)
+		}
+		if tt.field.Key != tt.key {
+			t.Errorf("field key = %q, want %q", tt.field.Key, tt.key)
+		}
+		if tt.field.String != tt.attr.Value.AsString() {
+			t.Errorf("%s: field value = %q, attribute value = %q", tt.key, tt.field.String, tt.attr.Value.AsString())
+		}
+	}
+}
+
+func TestAttributesFromFieldsRoundTrip(t *testing.T) {
+	attrs := []attribute.KeyValue{
+		attribute.Bool("ok", true),
+		attribute.Float64("ratio", 0.5),
+		attribute.Int64("count", 3),
+		TenantID("t1"),
+	}
+	fields := make([]zap.Field, len(attrs))
+	for i, attr := range attrs {
+		fields[i] = ZapField(attr)
+	}
+
+	got := attribute.NewSet(attributesFromFields(fields)...)
+	if want := attribute.NewSet(attrs...); !got.Equals(&want) {
+		t.Errorf("round trip = %v, want %v", got.ToSlice(), want.ToSlice())
+	}
+}
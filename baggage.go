@@ -0,0 +1,48 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// SetBaggage returns a copy of ctx whose baggage carries the key and value, replacing
+// any existing member with the same key. The key must be a valid W3C baggage token;
+// the value may be any UTF-8 string and is percent-encoded when propagated.
+func SetBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	// NewMemberRaw accepts any UTF-8 key, but other services only parse W3C tokens
+	if !isBaggageToken(key) {
+		return ctx, fmt.Errorf("invalid baggage key %q: must be a non-empty token of ASCII letters, digits or !#$%%&'*+-.^_`|~", key)
+	}
+	member, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx, fmt.Errorf("invalid baggage member %q: %w", key, err)
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to set baggage member %q: %w", key, err)
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// GetBaggage returns the value of the baggage member with the given key, or "" if there is none
+func GetBaggage(ctx context.Context, key string) string {
+	return baggage.FromContext(ctx).Member(key).Value()
+}
+
+// isBaggageToken reports whether key is a token as defined by RFC 7230, the syntax
+// the W3C baggage spec requires of keys
+func isBaggageToken(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		isAlnum := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !isAlnum && !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBaggageRoundTrip(t *testing.T) {
+	ctx, err := SetBaggage(context.Background(), "tenant", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = SetBaggage(ctx, "note", "café; a=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = SetBaggage(ctx, "tenant", "globex")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{"tenant": "globex", "note": "café; a=b", "missing": ""} {
+		if got := GetBaggage(ctx, key); got != want {
+			t.Errorf("GetBaggage(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSetBaggageRejectsInvalidKeys(t *testing.T) {
+	parent, err := SetBaggage(context.Background(), "tenant", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "user id", "tenant;region", "naïve"} {
+		ctx, err := SetBaggage(parent, key, "value")
+		if err == nil {
+			t.Errorf("SetBaggage(%q) accepted an invalid key", key)
+		}
+		if ctx != parent {
+			t.Errorf("SetBaggage(%q) returned a different context on error", key)
+		}
+	}
+}
+
+func TestSetBaggageRejectsInvalidValues(t *testing.T) {
+	if _, err := SetBaggage(context.Background(), "tenant", "\xff"); err == nil {
+		t.Error("SetBaggage accepted a value that is not UTF-8")
+	}
+}
@@ -0,0 +1,102 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// errCircuitOpen is returned instead of exporting while the circuit breaker is open
+var errCircuitOpen = errors.New("export skipped: circuit breaker is open")
+
+// circuitBreaker stops calling a failing exporter for a cooldown after consecutive failures.
+// Once the cooldown elapses a single export is let through as a probe: success closes the
+// breaker, failure opens it for another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker creates a closed circuit breaker from the configuration
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	config = config.withDefaults()
+	return &circuitBreaker{
+		threshold: config.FailureThreshold,
+		cooldown:  config.Cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether an export may be attempted
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	now := b.now()
+	if now.Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	// Restart the cooldown so only this export probes the exporter
+	b.openedAt = now
+	return true
+}
+
+// record updates the breaker with the outcome of an export
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// breakerSpanExporter guards a span exporter with a circuit breaker
+type breakerSpanExporter struct {
+	sdktrace.SpanExporter
+	breaker *circuitBreaker
+}
+
+// ExportSpans exports spans unless the breaker is open
+func (e *breakerSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if !e.breaker.allow() {
+		return errCircuitOpen
+	}
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.breaker.record(err)
+	return err
+}
+
+// breakerMetricExporter guards a metric exporter with a circuit breaker
+type breakerMetricExporter struct {
+	sdkmetric.Exporter
+	breaker *circuitBreaker
+}
+
+// Export exports metrics unless the breaker is open
+func (e *breakerMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if !e.breaker.allow() {
+		return errCircuitOpen
+	}
+	err := e.Exporter.Export(ctx, rm)
+	e.breaker.record(err)
+	return err
+}
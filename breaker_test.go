@@ -0,0 +1,154 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var errCollectorDown = errors.New("collector unavailable")
+
+// flakySpanExporter records spans while up and fails every export while down
+type flakySpanExporter struct {
+	mu       sync.Mutex
+	down     bool
+	names    []string
+	attempts int
+}
+
+func (e *flakySpanExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attempts++
+	if e.down {
+		return errCollectorDown
+	}
+	for _, span := range spans {
+		e.names = append(e.names, span.Name())
+	}
+	return nil
+}
+
+func (e *flakySpanExporter) Shutdown(context.Context) error { return nil }
+
+// flakyMetricExporter records collections while up and fails every export while down.
+// Periodic readers reuse collections once exported, so the int64 counter totals are
+// also captured at export time.
+type flakyMetricExporter struct {
+	mu       sync.Mutex
+	down     bool
+	exported []*metricdata.ResourceMetrics
+	counters map[string]int64
+}
+
+func (e *flakyMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (e *flakyMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *flakyMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.down {
+		return errCollectorDown
+	}
+	e.exported = append(e.exported, rm)
+	if e.counters == nil {
+		e.counters = make(map[string]int64)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				var total int64
+				for _, dp := range sum.DataPoints {
+					total += dp.Value
+				}
+				e.counters[m.Name] = total
+			}
+		}
+	}
+	return nil
+}
+
+// counter returns the total last exported for the named counter
+func (e *flakyMetricExporter) counter(name string) (int64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	total, ok := e.counters[name]
+	return total, ok
+}
+
+func (e *flakyMetricExporter) ForceFlush(context.Context) error { return nil }
+func (e *flakyMetricExporter) Shutdown(context.Context) error   { return nil }
+
+func TestBreakerSpanExporter(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, Cooldown: time.Minute})
+	breaker.now = clock.Now
+	next := &flakySpanExporter{down: true}
+	exporter := &breakerSpanExporter{SpanExporter: next, breaker: breaker}
+
+	steps := []struct {
+		name     string
+		advance  time.Duration
+		down     bool
+		want     error
+		attempts int
+	}{
+		{"first failure", 0, true, errCollectorDown, 1},
+		{"second failure", 0, true, errCollectorDown, 2},
+		{"third failure opens", 0, true, errCollectorDown, 3},
+		{"open skips the exporter", 0, true, errCircuitOpen, 3},
+		{"still open within the cooldown", 59 * time.Second, true, errCircuitOpen, 3},
+		{"failed probe after the cooldown", time.Second, true, errCollectorDown, 4},
+		{"failed probe reopens", 0, true, errCircuitOpen, 4},
+		{"successful probe closes", time.Minute, false, nil, 5},
+		{"closed exports again", 0, false, nil, 6},
+	}
+	for _, step := range steps {
+		clock.advance(step.advance)
+		next.down = step.down
+		err := exporter.ExportSpans(ctx, spanStubs(step.name))
+		if !errors.Is(err, step.want) {
+			t.Errorf("%s: ExportSpans() = %v, want %v", step.name, err, step.want)
+		}
+		if next.attempts != step.attempts {
+			t.Errorf("%s: exporter called %d times, want %d", step.name, next.attempts, step.attempts)
+		}
+	}
+}
+
+func TestBreakerMetricExporter(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute})
+	breaker.now = clock.Now
+	next := &flakyMetricExporter{down: true}
+	exporter := &breakerMetricExporter{Exporter: next, breaker: breaker}
+
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); !errors.Is(err, errCollectorDown) {
+		t.Errorf("Export() = %v, want the exporter's error", err)
+	}
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("Export() = %v, want errCircuitOpen once the threshold is reached", err)
+	}
+
+	clock.advance(time.Minute)
+	next.down = false
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Errorf("Export() = %v, want the probe to go through", err)
+	}
+	if len(next.exported) != 1 {
+		t.Errorf("exporter received %d exports, want the probe", len(next.exported))
+	}
+}
@@ -1,5 +1,20 @@
 package observability
 
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap/zapcore"
+)
+
 // LogLevel defines the logging level
 type LogLevel int
 
@@ -27,6 +42,77 @@ type TracingConfig struct {
 	Endpoint       string
 	Enabled        bool
 	SamplingRate   float64
+	// MaxSpansPerSecond caps the number of root spans sampled per second, with child spans
+	// following their root so traces are never cut short; zero disables the cap
+	MaxSpansPerSecond int
+	// ConsoleExporter pretty-prints spans to stdout, alongside OTLP or on its own when Endpoint is empty
+	ConsoleExporter bool
+	// ConsoleWriter receives the console exporter's output instead of stdout
+	ConsoleWriter io.Writer
+	// SamplingRules force the sampling decision for matching root span names before
+	// SamplingRate applies; child spans follow their root
+	SamplingRules []SamplingRule
+	// Retry configures export retries; nil keeps the SDK defaults
+	Retry *RetryConfig
+	// FailOpen falls back to a no-op tracer with a warning when the exporter cannot be created
+	FailOpen bool
+	// EmitSpanMetrics records span durations in the span_duration_seconds histogram; requires metrics
+	EmitSpanMetrics bool
+	// Exporter selects the span exporter: ExporterOTLP (default) or ExporterZipkin.
+	// For Zipkin, Endpoint is the collector URL, e.g. http://localhost:9411/api/v2/spans
+	Exporter string
+	// ServiceInstanceID identifies this replica; empty falls back to SERVICE_INSTANCE_ID
+	// and then to an ID generated once per process
+	ServiceInstanceID string
+	// Endpoints lists additional collectors that receive every span alongside Endpoint,
+	// e.g. to dual-write during a collector migration
+	Endpoints []string
+	// Compression selects the OTLP payload compression: CompressionNone (default) or CompressionGzip
+	Compression string
+	// Sanitizer rewrites or drops attributes before they are exported, e.g. to mask PII
+	Sanitizer AttributeSanitizer
+	// MaxAttributeValueLength truncates longer string span attribute values, marking
+	// them with a trailing "..."; zero means unlimited
+	MaxAttributeValueLength int
+	// CircuitBreaker stops exporting for a cooldown after consecutive failures; nil never stops
+	CircuitBreaker *CircuitBreakerConfig
+	// MinSpanDuration only exports spans that took at least this long or ended with an
+	// error status; zero exports every sampled span. Span metrics still cover every span.
+	MinSpanDuration time.Duration
+	// DisableGlobalProvider keeps the tracer provider and propagator out of the otel globals
+	// so they don't clobber other libraries' and several can coexist; only our own Tracer
+	// uses the provider, and the HTTP and gRPC helpers use whatever propagator the
+	// application registers. It is a disable flag so the zero config still registers globally.
+	DisableGlobalProvider bool
+	// RecordSamplingRatio adds the ratio a root span was sampled at to it as sampling.ratio;
+	// spans whose decision a sampling rule forced don't get it
+	RecordSamplingRatio bool
+	// SpanExporter replaces the collector exporters, e.g. with a fake in tests; nil builds
+	// them from Exporter and the endpoints
+	SpanExporter sdktrace.SpanExporter
+	// DeploymentSlot tags telemetry with the blue-green slot, e.g. "blue"; empty falls
+	// back to DEPLOYMENT_SLOT and is omitted if that is unset too
+	DeploymentSlot string
+	// SpoolDir, if set, is a directory spans that fail to export are written to and
+	// replayed from once the collector accepts spans again
+	SpoolDir string
+	// AdaptiveSampling raises the sampling ratio while spans are failing and lets it decay
+	// back once they stop; nil samples at the fixed SamplingRate
+	AdaptiveSampling *AdaptiveSamplingConfig
+}
+
+// Span exporters supported by TracingConfig.Exporter
+const (
+	ExporterOTLP   = "otlp"
+	ExporterZipkin = "zipkin"
+)
+
+// SamplingRule forces the sampling decision for spans whose name matches Pattern
+type SamplingRule struct {
+	// Pattern is matched against the span name using path.Match syntax
+	Pattern string
+	// Sample records matching spans when true and drops them when false
+	Sample bool
 }
 
 // LogConfig holds configuration for the logger
@@ -35,6 +121,53 @@ type LogConfig struct {
 	Format      LogFormat
 	OutputPaths []string
 	Development bool
+	// Writer receives log output in addition to OutputPaths; stdout is only
+	// used by default when neither is set
+	Writer io.Writer
+	// LevelOutputs additionally routes entries at or above each level to the given paths
+	LevelOutputs map[LogLevel][]string
+	// Hooks observe every written entry; they run synchronously on the logging
+	// path so should hand off slow work, and their errors are reported to stderr
+	Hooks []func(zapcore.Entry) error
+	// IncludeGoroutineID adds a goroutine field to every entry; it is costly and meant for development
+	IncludeGoroutineID bool
+	// IncludeHostFields adds the hostname and pid, resolved once at construction, to every entry
+	IncludeHostFields bool
+	// StructuredStacktrace encodes stacktraces as an array of frames with function, file
+	// and line instead of a multi-line string
+	StructuredStacktrace bool
+	// AuditOutputPaths receive audit entries; empty writes them to the main outputs
+	AuditOutputPaths []string
+	// EncoderKeys renames the keys of the standard entry fields
+	EncoderKeys EncoderKeys
+	// Color highlights levels with ANSI colors; it only applies to the console format in development
+	Color bool
+	// Sampling limits repeated entries; the first entry with each message is always written.
+	// Nil writes every entry.
+	Sampling *LogSamplingConfig
+	// DedupWindow writes identical entries (same level, message and fields) once per
+	// window, followed by a copy with a repeated count when the window closes or the logger
+	// is synced; zero disables it
+	DedupWindow time.Duration
+}
+
+// LogSamplingConfig configures log sampling. Within each Tick, the first Initial entries
+// with the same level and message are written, then every Thereafter-th one.
+type LogSamplingConfig struct {
+	Initial    int
+	Thereafter int
+	// Tick is the sampling interval; zero uses one second
+	Tick time.Duration
+}
+
+// EncoderKeys overrides the keys of the standard entry fields. Empty keys keep the defaults.
+type EncoderKeys struct {
+	MessageKey    string
+	TimeKey       string
+	LevelKey      string
+	NameKey       string
+	CallerKey     string
+	StacktraceKey string
 }
 
 // MetricsConfig holds configuration for metrics
@@ -44,6 +177,188 @@ type MetricsConfig struct {
 	Environment    string
 	Enabled        bool
 	Endpoint       string
+	// DropAttributes lists attribute keys stripped from every instrument before export
+	DropAttributes []string
+	// ExponentialHistograms lists histogram names aggregated as base-2 exponential histograms
+	ExponentialHistograms []string
+	// ExponentialMaxSize is the maximum bucket count per range; zero uses the SDK default of 160
+	ExponentialMaxSize int32
+	// ExponentialMaxScale is the maximum resolution scale; zero uses the SDK default of 20
+	ExponentialMaxScale int32
+	// DetachContext records measurements with a context that ignores the caller's
+	// cancellation while keeping its values, so counts survive canceled requests
+	DetachContext bool
+	// ConsoleExporter prints metrics to stdout, alongside OTLP or on its own when Endpoint is empty
+	ConsoleExporter bool
+	// ConsoleWriter receives the console exporter's output instead of stdout
+	ConsoleWriter io.Writer
+	// Retry configures export retries; nil keeps the SDK defaults
+	Retry *RetryConfig
+	// FailOpen falls back to no-op metrics with a warning when the exporter cannot be created
+	FailOpen bool
+	// ServiceInstanceID identifies this replica; empty falls back to SERVICE_INSTANCE_ID
+	// and then to an ID generated once per process
+	ServiceInstanceID string
+	// Compression selects the OTLP payload compression: CompressionNone (default) or CompressionGzip
+	Compression string
+	// Sanitizer rewrites or drops attributes before they are exported, e.g. to mask PII.
+	// Attributes recorded on instruments from the MeterProvider directly, such as span
+	// metrics, are dropped if the sanitizer would change them, so it must leave its own
+	// output unchanged.
+	Sanitizer AttributeSanitizer
+	// InstrumentTTL stops exporting cumulative counter series nothing was added to for this
+	// long, e.g. counters of tenants that went idle; zero exports every series forever.
+	// Up-down counters, histograms and gauges are always exported.
+	InstrumentTTL time.Duration
+	// CircuitBreaker stops exporting for a cooldown after consecutive failures; nil never stops
+	CircuitBreaker *CircuitBreakerConfig
+	// FlushOnSignal exports metrics immediately whenever the process receives SIGUSR1;
+	// it is ignored on platforms without SIGUSR1
+	FlushOnSignal bool
+	// DisableGlobalProvider keeps the meter provider out of the otel globals so it doesn't
+	// clobber other libraries' and several can coexist; only our own Metrics uses it. It is
+	// a disable flag so the zero config still registers globally.
+	DisableGlobalProvider bool
+	// NamePrefix is prepended to the name of every instrument, e.g. "orders."; instruments
+	// are still looked up and listed in ExponentialHistograms by their unprefixed names
+	NamePrefix string
+	// MetricExporter replaces the OTLP exporter, e.g. with a fake in tests; nil builds it from Endpoint
+	MetricExporter sdkmetric.Exporter
+	// DeploymentSlot tags telemetry with the blue-green slot, e.g. "blue"; empty falls
+	// back to DEPLOYMENT_SLOT and is omitted if that is unset too
+	DeploymentSlot string
+	// ValidateUnits makes creating an instrument fail with ErrUnknownUnit if its unit is
+	// not a recognized UCUM code, catching typos such as "seconds" for "s"
+	ValidateUnits bool
+	// MaxInstruments caps the number of distinct instruments, counting those of tenant
+	// scopes; creating more fails with ErrTooManyInstruments. Zero means unlimited.
+	MaxInstruments int
+	// EnableSnapshot keeps a copy of the aggregated metrics in process for Metrics.Snapshot,
+	// at the cost of aggregating every measurement once more
+	EnableSnapshot bool
+	// SpoolDir, if set, is a directory collections that fail to export are written to and
+	// replayed from once the collector accepts metrics again
+	SpoolDir string
+}
+
+// OTLP payload compressions supported by TracingConfig.Compression and MetricsConfig.Compression
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// validateCompression reports an error for compressions the OTLP exporters do not support
+func validateCompression(compression string) error {
+	switch compression {
+	case "", CompressionNone, CompressionGzip:
+		return nil
+	default:
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+}
+
+// RetryConfig configures how OTLP exporters retry failed exports.
+// Zero intervals fall back to the SDK defaults.
+type RetryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// Defaults for export retries, matching the OpenTelemetry SDK
+const (
+	defaultRetryInitialInterval = 5 * time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = time.Minute
+)
+
+// withDefaults returns a copy of the retry configuration with zero intervals replaced by SDK defaults
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.InitialInterval == 0 {
+		c.InitialInterval = defaultRetryInitialInterval
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = defaultRetryMaxInterval
+	}
+	if c.MaxElapsedTime == 0 {
+		c.MaxElapsedTime = defaultRetryMaxElapsedTime
+	}
+	return c
+}
+
+// AdaptiveSamplingConfig configures how the sampling ratio follows the error rate.
+// The ratio moves linearly from Floor with no failed spans to Ceiling once the
+// fraction of failed spans reaches TargetErrorRate. Zero values fall back to the defaults.
+type AdaptiveSamplingConfig struct {
+	// TargetErrorRate is the fraction of failed spans at which the ratio reaches Ceiling
+	TargetErrorRate float64
+	// Floor is the ratio while no spans fail; zero uses the tracing SamplingRate. Failures
+	// are only seen in sampled spans, so a floor of zero would never raise the ratio and
+	// falls back to 0.01.
+	Floor float64
+	// Ceiling is the highest ratio, used at or above TargetErrorRate; zero means 1
+	Ceiling float64
+	// Window is how often the error rate is re-evaluated. Each window the smoothed rate
+	// moves halfway to the observed one, so it decays by half per window without failures.
+	Window time.Duration
+}
+
+// Defaults for adaptive sampling
+const (
+	defaultAdaptiveTargetErrorRate = 0.05
+	defaultAdaptiveFloor           = 0.01
+	defaultAdaptiveCeiling         = 1.0
+	defaultAdaptiveWindow          = time.Minute
+)
+
+// withDefaults returns a copy of the adaptive sampling configuration with zero values
+// replaced by defaults, flooring at samplingRate unless a floor is set
+func (c AdaptiveSamplingConfig) withDefaults(samplingRate float64) AdaptiveSamplingConfig {
+	if c.TargetErrorRate <= 0 {
+		c.TargetErrorRate = defaultAdaptiveTargetErrorRate
+	}
+	if c.Floor <= 0 {
+		c.Floor = samplingRate
+	}
+	if c.Floor <= 0 {
+		c.Floor = defaultAdaptiveFloor
+	}
+	if c.Ceiling <= 0 {
+		c.Ceiling = defaultAdaptiveCeiling
+	}
+	c.Floor = math.Min(1, c.Floor)
+	c.Ceiling = math.Max(c.Floor, math.Min(1, c.Ceiling))
+	if c.Window <= 0 {
+		c.Window = defaultAdaptiveWindow
+	}
+	return c
+}
+
+// CircuitBreakerConfig configures when a failing exporter is skipped.
+// Zero values fall back to the defaults.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed exports that opens the breaker
+	FailureThreshold int
+	// Cooldown is how long exports are skipped before one is let through to probe the collector
+	Cooldown time.Duration
+}
+
+// Defaults for the export circuit breaker
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// withDefaults returns a copy of the circuit breaker configuration with zero values replaced by defaults
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = defaultBreakerCooldown
+	}
+	return c
 }
 
 // ObservabilityConfig holds all observability configuration
@@ -52,6 +367,9 @@ type ObservabilityConfig struct {
 	Tracing TracingConfig
 	Metrics MetricsConfig
 	Service ServiceConfig
+	// ShutdownTimeout bounds the cleanup returned by InitializeObservabilityProviderFromConfig;
+	// zero uses 5 seconds
+	ShutdownTimeout time.Duration
 }
 
 // ServiceConfig holds service information
@@ -61,6 +379,59 @@ type ServiceConfig struct {
 	Environment string
 }
 
+// Environment variables consulted for the OTLP endpoint when none is configured
+const (
+	envOTLPEndpoint        = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envOTLPTracesEndpoint  = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	envOTLPMetricsEndpoint = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	defaultOTLPEndpoint    = "localhost:4317"
+)
+
+// resolveEndpoint returns the explicitly configured endpoint if set, else the
+// signal-specific environment variable, else OTEL_EXPORTER_OTLP_ENDPOINT, else
+// the default collector address
+func resolveEndpoint(explicit, signalEnv string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if endpoint := os.Getenv(signalEnv); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv(envOTLPEndpoint); endpoint != "" {
+		return endpoint
+	}
+	return defaultOTLPEndpoint
+}
+
+// envServiceInstanceID overrides the generated service instance ID
+const envServiceInstanceID = "SERVICE_INSTANCE_ID"
+
+// processInstanceID is generated once so every signal in the process reports the same instance
+var processInstanceID = uuid.NewString()
+
+// resolveInstanceID returns the explicitly configured instance ID if set, else
+// SERVICE_INSTANCE_ID, else the ID generated for this process
+func resolveInstanceID(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if instanceID := os.Getenv(envServiceInstanceID); instanceID != "" {
+		return instanceID
+	}
+	return processInstanceID
+}
+
+// envDeploymentSlot sets the deployment slot when none is configured
+const envDeploymentSlot = "DEPLOYMENT_SLOT"
+
+// resolveDeploymentSlot returns the explicitly configured slot if set, else DEPLOYMENT_SLOT
+func resolveDeploymentSlot(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	return os.Getenv(envDeploymentSlot)
+}
+
 // ParseLogLevel converts a string log level to a LogLevel enum
 func ParseLogLevel(level string) LogLevel {
 	switch level {
@@ -90,3 +461,26 @@ func ParseLogFormat(format string) LogFormat {
 		return JSONFormat // Default to JSONFormat for unknown values
 	}
 }
+
+// ParseSamplingRate converts a sampling rate written as a fraction ("0.1", "1") or a
+// percentage ("10%", "100%") to a fraction in [0, 1]
+func ParseSamplingRate(rate string) (float64, error) {
+	value := strings.TrimSpace(rate)
+	percent := strings.HasSuffix(value, "%")
+	if percent {
+		value = strings.TrimSpace(strings.TrimSuffix(value, "%"))
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sampling rate %q: %w", rate, err)
+	}
+	if percent {
+		parsed /= 100
+	}
+	// The negated comparison also rejects NaN
+	if !(parsed >= 0 && parsed <= 1) {
+		return 0, fmt.Errorf("sampling rate %q is outside 0%%-100%%", rate)
+	}
+	return parsed, nil
+}
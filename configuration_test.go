@@ -0,0 +1,77 @@
+package observability
+
+import "testing"
+
+func TestResolveEndpointPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		explicit  string
+		signalEnv string
+		sharedEnv string
+		want      string
+	}{
+		{"config wins", "config:4317", "signal:4317", "shared:4317", "config:4317"},
+		{"signal env", "", "signal:4317", "shared:4317", "signal:4317"},
+		{"shared env", "", "", "shared:4317", "shared:4317"},
+		{"default", "", "", "", defaultOTLPEndpoint},
+	}
+	for _, signal := range []string{envOTLPTracesEndpoint, envOTLPMetricsEndpoint} {
+		for _, tt := range tests {
+			t.Run(signal+"/"+tt.name, func(t *testing.T) {
+				t.Setenv(envOTLPTracesEndpoint, "")
+				t.Setenv(envOTLPMetricsEndpoint, "")
+				t.Setenv(signal, tt.signalEnv)
+				t.Setenv(envOTLPEndpoint, tt.sharedEnv)
+
+				if got := resolveEndpoint(tt.explicit, signal); got != tt.want {
+					t.Errorf("resolveEndpoint() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestResolveEndpointIgnoresOtherSignal(t *testing.T) {
+	t.Setenv(envOTLPEndpoint, "")
+	t.Setenv(envOTLPMetricsEndpoint, "metrics:4317")
+	t.Setenv(envOTLPTracesEndpoint, "")
+
+	if got := resolveEndpoint("", envOTLPTracesEndpoint); got != defaultOTLPEndpoint {
+		t.Errorf("traces endpoint = %q, want the default rather than the metrics one", got)
+	}
+}
+
+func TestParseSamplingRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		want    float64
+		wantErr bool
+	}{
+		{"100%", 1, false},
+		{"10%", 0.1, false},
+		{" 2.5 % ", 0.025, false},
+		{"0%", 0, false},
+		{"0.1", 0.1, false},
+		{"1", 1, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"%", 0, true},
+		{"ten percent", 0, true},
+		{"150%", 0, true},
+		{"1.5", 0, true},
+		{"-0.1", 0, true},
+		{"-5%", 0, true},
+		{"NaN", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+			got, err := ParseSamplingRate(tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSamplingRate(%q) error = %v, want error %v", tt.rate, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSamplingRate(%q) = %g, want %g", tt.rate, got, tt.want)
+			}
+		})
+	}
+}
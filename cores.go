@@ -0,0 +1,348 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// goroutineCore adds the ID of the logging goroutine to every entry
+type goroutineCore struct {
+	zapcore.Core
+}
+
+// With adds structured context to the wrapped core
+func (c *goroutineCore) With(fields []zapcore.Field) zapcore.Core {
+	return &goroutineCore{Core: c.Core.With(fields)}
+}
+
+// Check registers this core so Write can add the goroutine field
+func (c *goroutineCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write adds the goroutine field before delegating to the wrapped core
+func (c *goroutineCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, append(fields, zap.Uint64("goroutine", goroutineID())))
+}
+
+// goroutineID parses the current goroutine's ID from its stack header
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+
+	// The header has the form "goroutine <id> [running]:"
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
+
+// contextCore adds the fields extracted from a context to the single entry checked
+// through it when that entry is written, so checking an entry costs no field encoding
+type contextCore struct {
+	zapcore.Core
+	ctx context.Context
+	// fatal runs before a fatal entry is written, see Logger.recordFatal
+	fatal func(context.Context, string)
+	// checked is the wrapped core's entry, written with the context's fields
+	checked *zapcore.CheckedEntry
+}
+
+// With adds structured context to the wrapped core
+func (c *contextCore) With(fields []zapcore.Field) zapcore.Core {
+	return &contextCore{Core: c.Core.With(fields), ctx: c.ctx, fatal: c.fatal}
+}
+
+// Check lets the wrapped core decide, then registers this core to write in its place
+func (c *contextCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	c.checked = c.Core.Check(entry, nil)
+	if c.checked == nil {
+		return ce
+	}
+	return ce.AddCore(entry, c)
+}
+
+// Write writes the entry to the wrapped core's checked entry with the context's fields
+func (c *contextCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if entry.Level == zapcore.FatalLevel && c.fatal != nil {
+		c.fatal(c.ctx, entry.Message)
+	}
+	// The logger sets the caller and stack after checking, so take them from entry
+	c.checked.Entry = entry
+	c.checked.Write(append(fields[:len(fields):len(fields)], extractContextFields(c.ctx)...)...)
+	return nil
+}
+
+// structuredStackCore encodes entry stacktraces as an array of frames instead of a multi-line string
+type structuredStackCore struct {
+	zapcore.Core
+	key string
+}
+
+// With adds structured context to the wrapped core
+func (c *structuredStackCore) With(fields []zapcore.Field) zapcore.Core {
+	return &structuredStackCore{Core: c.Core.With(fields), key: c.key}
+}
+
+// Check registers this core so Write can restructure the stacktrace
+func (c *structuredStackCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write replaces the entry's stacktrace string with a stacktrace field before delegating to the wrapped core
+func (c *structuredStackCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if entry.Stack == "" {
+		return c.Core.Write(entry, fields)
+	}
+	frames := parseStack(entry.Stack)
+	entry.Stack = ""
+	return c.Core.Write(entry, append(fields, zap.Array(c.key, frames)))
+}
+
+// stackFrame is a single frame of a structured stacktrace
+type stackFrame struct {
+	function string
+	file     string
+	line     int
+}
+
+// MarshalLogObject encodes the frame's function, file and line
+func (f stackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.function)
+	enc.AddString("file", f.file)
+	enc.AddInt("line", f.line)
+	return nil
+}
+
+// stackFrames is a structured stacktrace
+type stackFrames []stackFrame
+
+// MarshalLogArray encodes each frame as an object
+func (frames stackFrames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, frame := range frames {
+		if err := enc.AppendObject(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseStack parses a zap stacktrace, which alternates function lines with
+// tab-indented "file:line" lines, into frames
+func parseStack(stack string) stackFrames {
+	lines := strings.Split(stack, "\n")
+	frames := make(stackFrames, 0, len(lines)/2)
+	for i := 0; i+1 < len(lines); i += 2 {
+		frame := stackFrame{function: lines[i]}
+		location := strings.TrimPrefix(lines[i+1], "\t")
+		if sep := strings.LastIndexByte(location, ':'); sep >= 0 {
+			frame.file = location[:sep]
+			frame.line, _ = strconv.Atoi(location[sep+1:])
+		} else {
+			frame.file = location
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// maxFirstSeenMessages bounds the messages firstSeenCore remembers; once reached, new
+// messages are sampled like repeats
+const maxFirstSeenMessages = 10000
+
+// firstSeenCore writes the first entry with each message unsampled and samples the rest
+type firstSeenCore struct {
+	zapcore.Core
+	unsampled zapcore.Core
+	seen      *seenMessages
+}
+
+// seenMessages records the messages that have already been logged
+type seenMessages struct {
+	mu       sync.Mutex
+	messages map[string]struct{}
+}
+
+// newFirstSeenCore wraps a sampled core so that the first entry with each message goes
+// to the unsampled core instead
+func newFirstSeenCore(sampled, unsampled zapcore.Core) *firstSeenCore {
+	return &firstSeenCore{
+		Core:      sampled,
+		unsampled: unsampled,
+		seen:      &seenMessages{messages: make(map[string]struct{})},
+	}
+}
+
+// With adds structured context to both wrapped cores
+func (c *firstSeenCore) With(fields []zapcore.Field) zapcore.Core {
+	return &firstSeenCore{
+		Core:      c.Core.With(fields),
+		unsampled: c.unsampled.With(fields),
+		seen:      c.seen,
+	}
+}
+
+// Check bypasses sampling for the first entry with a message. The entry still counts
+// against the sampler, so it is one of the tick's Initial entries rather than an extra one.
+func (c *firstSeenCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return ce
+	}
+	if c.seen.add(entry.Message) {
+		c.Core.Check(entry, nil)
+		return c.unsampled.Check(entry, ce)
+	}
+	return c.Core.Check(entry, ce)
+}
+
+// add records the message, reporting whether it had not been seen before
+func (s *seenMessages) add(message string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.messages[message]; ok || len(s.messages) >= maxFirstSeenMessages {
+		return false
+	}
+	s.messages[message] = struct{}{}
+	return true
+}
+
+// dedupCore suppresses entries identical to one written within the window, then writes
+// a copy of the entry with a repeated count once the window closes or the core is synced
+type dedupCore struct {
+	zapcore.Core
+	window time.Duration
+	// context identifies the fields added with With, which are part of an entry's identity
+	context string
+	state   *dedupState
+}
+
+// dedupState tracks the entries written within their window, shared by derived cores
+type dedupState struct {
+	mu      sync.Mutex
+	pending map[string]*dedupEntry
+}
+
+// dedupEntry is an entry written within its window and the count of duplicates suppressed since
+type dedupEntry struct {
+	core     zapcore.Core
+	entry    zapcore.Entry
+	fields   []zapcore.Field
+	repeated int
+	timer    *time.Timer
+}
+
+// newDedupCore wraps core so identical entries within window are written once
+func newDedupCore(core zapcore.Core, window time.Duration) *dedupCore {
+	return &dedupCore{
+		Core:   core,
+		window: window,
+		state:  &dedupState{pending: make(map[string]*dedupEntry)},
+	}
+}
+
+// With adds structured context to the wrapped core
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{
+		Core:    c.Core.With(fields),
+		window:  c.window,
+		context: c.context + encodeFields(fields),
+		state:   c.state,
+	}
+}
+
+// Check registers this core so Write can suppress duplicates
+func (c *dedupCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write writes the first of identical entries in a window and counts the rest
+func (c *dedupCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	key := strings.Join([]string{c.context, entry.Level.String(), entry.Message, encodeFields(fields)}, "\x00")
+
+	c.state.mu.Lock()
+	if pending, ok := c.state.pending[key]; ok {
+		pending.repeated++
+		c.state.mu.Unlock()
+		return nil
+	}
+	// The caller may reuse fields once Write returns
+	pending := &dedupEntry{core: c.Core, entry: entry, fields: append([]zapcore.Field(nil), fields...)}
+	c.state.pending[key] = pending
+	pending.timer = time.AfterFunc(c.window, func() {
+		c.state.mu.Lock()
+		// Sync may have written the summary already
+		if c.state.pending[key] != pending {
+			c.state.mu.Unlock()
+			return
+		}
+		delete(c.state.pending, key)
+		c.state.mu.Unlock()
+
+		_ = pending.summarize()
+	})
+	c.state.mu.Unlock()
+
+	return c.Core.Write(entry, fields)
+}
+
+// Sync writes the summaries of all open windows, so they aren't lost on shutdown, then
+// syncs the wrapped core. Entries after Sync start new windows.
+func (c *dedupCore) Sync() error {
+	c.state.mu.Lock()
+	pending := c.state.pending
+	c.state.pending = make(map[string]*dedupEntry)
+	c.state.mu.Unlock()
+
+	var errs []error
+	for _, p := range pending {
+		p.timer.Stop()
+		errs = append(errs, p.summarize())
+	}
+	return errors.Join(append(errs, c.Core.Sync())...)
+}
+
+// summarize writes a copy of the entry with the repeated count, if any duplicates were suppressed
+func (e *dedupEntry) summarize() error {
+	if e.repeated == 0 {
+		return nil
+	}
+	summary := e.entry
+	summary.Time = time.Now()
+	summary.Stack = ""
+	return e.core.Write(summary, append(e.fields, zap.Int("repeated", e.repeated)))
+}
+
+// encodeFields renders fields to a string identifying their keys and values
+func encodeFields(fields []zapcore.Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	// fmt prints maps with sorted keys, so equal fields render equally
+	return fmt.Sprint(enc.Fields)
+}
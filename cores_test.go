@@ -0,0 +1,163 @@
+package observability
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestGoroutineIDField(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		logger, buf := newTestLogger(t, &LogConfig{IncludeGoroutineID: enabled})
+		logger.Info(context.Background(), "working")
+
+		entries := logEntries(t, buf)
+		if len(entries) != 1 {
+			t.Fatalf("got %d entries, want 1", len(entries))
+		}
+		id, ok := entries[0]["goroutine"].(float64)
+		if ok != enabled {
+			t.Errorf("IncludeGoroutineID = %v: goroutine field present = %v", enabled, ok)
+		}
+		if enabled && id <= 0 {
+			t.Errorf("goroutine = %v, want a positive ID", id)
+		}
+	}
+}
+
+func TestGoroutineIDDiffersAcrossGoroutines(t *testing.T) {
+	ids := make(chan uint64, 2)
+	ids <- goroutineID()
+	go func() { ids <- goroutineID() }()
+	if first, second := <-ids, <-ids; first == second {
+		t.Errorf("both goroutines reported ID %d", first)
+	}
+}
+
+func TestStructuredStacktrace(t *testing.T) {
+	logger, buf := newTestLogger(t, &LogConfig{StructuredStacktrace: true})
+	logger.Error(context.Background(), "payment failed")
+
+	entry := logEntries(t, buf)[0]
+	frames, ok := entry["stacktrace"].([]interface{})
+	if !ok || len(frames) == 0 {
+		t.Fatalf("stacktrace = %#v, want an array of frames", entry["stacktrace"])
+	}
+	frame, _ := frames[0].(map[string]interface{})
+	function, _ := frame["function"].(string)
+	file, _ := frame["file"].(string)
+	if !strings.HasSuffix(function, ".TestStructuredStacktrace") || !strings.HasSuffix(file, "cores_test.go") {
+		t.Errorf("first frame = %v, want this test's function and file", frame)
+	}
+	if line, _ := frame["line"].(float64); line <= 0 {
+		t.Errorf("first frame line = %v, want a line number", frame["line"])
+	}
+}
+
+func TestStacktraceIsStringByDefault(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	logger.Error(context.Background(), "payment failed")
+
+	if stack, ok := logEntries(t, buf)[0]["stacktrace"].(string); !ok || !strings.Contains(stack, "\n\t") {
+		t.Errorf("stacktrace = %#v, want zap's multi-line string", stack)
+	}
+}
+
+// countMessages counts the logged entries with each message
+func countMessages(t *testing.T, buf *syncBuffer) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for _, entry := range logEntries(t, buf) {
+		message, _ := entry["message"].(string)
+		counts[message]++
+	}
+	return counts
+}
+
+func TestFirstSeenCoreWritesFirstOccurrence(t *testing.T) {
+	ctx := context.Background()
+	logger, buf := newTestLogger(t, &LogConfig{
+		Sampling: &LogSamplingConfig{Initial: 1, Thereafter: 2, Tick: time.Hour},
+	})
+
+	for i := 0; i < 5; i++ {
+		logger.Info(ctx, "repeated")
+	}
+	logger.Info(ctx, "new")
+
+	counts := countMessages(t, buf)
+	// The first is also the tick's one initial entry, then every second repeat is written
+	if counts["repeated"] != 3 {
+		t.Errorf("repeated written %d times, want 3", counts["repeated"])
+	}
+	if counts["new"] != 1 {
+		t.Errorf("new written %d times, want its first occurrence", counts["new"])
+	}
+}
+
+func TestFirstSeenCoreCountsAgainstInitial(t *testing.T) {
+	ctx := context.Background()
+	logger, buf := newTestLogger(t, &LogConfig{
+		Sampling: &LogSamplingConfig{Initial: 1, Tick: time.Hour},
+	})
+
+	for i := 0; i < 3; i++ {
+		logger.Info(ctx, "repeated")
+	}
+
+	if n := countMessages(t, buf)["repeated"]; n != 1 {
+		t.Errorf("repeated written %d times, want 1 with Initial 1", n)
+	}
+}
+
+func TestDedupCore(t *testing.T) {
+	logger, buf := newTestLogger(t, &LogConfig{DedupWindow: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		logger.Warn(ctx, "retrying connection", zap.String("host", "db-1"))
+	}
+	logger.Warn(ctx, "retrying connection", zap.String("host", "db-2"))
+
+	var entries []map[string]interface{}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if entries = logEntries(t, buf); len(entries) >= 3 {
+			break
+		}
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want the first of each and one summary: %v", len(entries), entries)
+	}
+	for i, want := range []struct {
+		host     string
+		repeated interface{}
+	}{{"db-1", nil}, {"db-2", nil}, {"db-1", float64(19)}} {
+		if entries[i]["message"] != "retrying connection" || entries[i]["host"] != want.host || entries[i]["repeated"] != want.repeated {
+			t.Errorf("entry %d = %v, want host %s repeated %v", i, entries[i], want.host, want.repeated)
+		}
+	}
+}
+
+func TestDedupCoreSyncWritesPendingSummaries(t *testing.T) {
+	logger, buf := newTestLogger(t, &LogConfig{DedupWindow: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		logger.Warn(ctx, "retrying connection", zap.String("host", "db-1"))
+	}
+	logger.Info(ctx, "shutting down")
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logEntries(t, buf)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want both entries and the summary: %v", len(entries), entries)
+	}
+	if entries[2]["message"] != "retrying connection" || entries[2]["repeated"] != float64(4) {
+		t.Errorf("entry = %v, want the summary with 4 repeats", entries[2])
+	}
+}
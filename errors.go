@@ -0,0 +1,61 @@
+package observability
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedError annotates an error with the trace and span it occurred in
+type tracedError struct {
+	err     error
+	traceID string
+	spanID  string
+}
+
+// Error returns the message of the wrapped error unchanged
+func (e *tracedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *tracedError) Unwrap() error {
+	return e.err
+}
+
+// WrapError annotates err with the trace and span IDs of the span in ctx so they can be
+// recovered with TraceIDFromError once the error has bubbled up. The error message is
+// unchanged; err is returned as is when it is nil or ctx has no span.
+func WrapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return err
+	}
+	return &tracedError{
+		err:     err,
+		traceID: spanCtx.TraceID().String(),
+		spanID:  spanCtx.SpanID().String(),
+	}
+}
+
+// TraceIDFromError returns the trace ID attached to err or any error it wraps by WrapError, or ""
+func TraceIDFromError(err error) string {
+	var traced *tracedError
+	if errors.As(err, &traced) {
+		return traced.traceID
+	}
+	return ""
+}
+
+// SpanIDFromError returns the span ID attached to err or any error it wraps by WrapError, or ""
+func SpanIDFromError(err error) string {
+	var traced *tracedError
+	if errors.As(err, &traced) {
+		return traced.spanID
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestWrapError(t *testing.T) {
+	tracer, _ := NewTestTracer()
+	ctx, span := tracer.Start(context.Background(), "load config")
+	defer span.End()
+
+	err := WrapError(ctx, fs.ErrNotExist)
+	wrapped := fmt.Errorf("startup: %w", err)
+
+	spanCtx := span.SpanContext()
+	if got := TraceIDFromError(wrapped); got != spanCtx.TraceID().String() {
+		t.Errorf("TraceIDFromError() = %q, want %q", got, spanCtx.TraceID())
+	}
+	if got := SpanIDFromError(wrapped); got != spanCtx.SpanID().String() {
+		t.Errorf("SpanIDFromError() = %q, want %q", got, spanCtx.SpanID())
+	}
+	if !errors.Is(wrapped, fs.ErrNotExist) || errors.Unwrap(err) != fs.ErrNotExist {
+		t.Error("wrapped error no longer matches the original")
+	}
+	if err.Error() != fs.ErrNotExist.Error() {
+		t.Errorf("Error() = %q, want the original message", err.Error())
+	}
+}
+
+func TestWrapErrorWithoutSpan(t *testing.T) {
+	if err := WrapError(context.Background(), fs.ErrNotExist); err != fs.ErrNotExist {
+		t.Errorf("WrapError() = %#v, want the error returned as is", err)
+	}
+	if err := WrapError(context.Background(), nil); err != nil {
+		t.Errorf("WrapError(nil) = %v, want nil", err)
+	}
+	if got := TraceIDFromError(errors.New("plain")); got != "" {
+		t.Errorf("TraceIDFromError() = %q, want empty for an unwrapped error", got)
+	}
+}
@@ -0,0 +1,63 @@
+package observability
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fatalFlushTimeout bounds flushing telemetry before a fatal entry exits the process
+const fatalFlushTimeout = 5 * time.Second
+
+// exitFunc exits the process after a fatal entry; tests replace it
+var exitFunc = os.Exit
+
+// fatalHook flushes spans and syncs logs once a fatal entry is written, then exits
+type fatalHook struct {
+	tracer *Tracer
+	logger *zap.Logger
+}
+
+// OnWrite runs after the fatal entry is written
+func (h *fatalHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalFlushTimeout)
+	defer cancel()
+
+	// Nothing can report failures at this point, so flush as much as possible
+	if h.tracer != nil {
+		_ = h.tracer.ForceFlush(ctx)
+	}
+	_ = h.logger.Sync()
+	exitFunc(1)
+}
+
+// FlushOnFatal returns a logger that, on a fatal entry, records it on the active span
+// and ends the span, exports pending spans and syncs logs before the process exits,
+// so traces of fatal conditions are not lost. With a nil tracer only logs are synced.
+func (l *Logger) FlushOnFatal(tracer *Tracer) *Logger {
+	hook := &fatalHook{tracer: tracer}
+	c := l.derive(func(logger *zap.Logger) *zap.Logger {
+		return logger.WithOptions(zap.WithFatalHook(hook))
+	})
+	hook.logger = c.logger
+	c.fatalSpans = tracer != nil
+	return c
+}
+
+// recordFatal marks the active span as failed with the fatal message and ends it,
+// since the process exits before deferred span ends run
+func (l *Logger) recordFatal(ctx context.Context, msg string) {
+	if !l.fatalSpans {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("fatal", trace.WithAttributes(attribute.String("message", msg)))
+	span.SetStatus(codes.Error, msg)
+	span.End()
+}
@@ -0,0 +1,110 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// flushRecorder records spans and reports each flush
+type flushRecorder struct {
+	*tracetest.SpanRecorder
+	flushed func()
+}
+
+func (r *flushRecorder) ForceFlush(ctx context.Context) error {
+	r.flushed()
+	return r.SpanRecorder.ForceFlush(ctx)
+}
+
+// replaceExit swaps exitFunc for one that records the exit code as a step, restoring it after the test
+func replaceExit(t *testing.T, steps *[]string) {
+	t.Helper()
+	original := exitFunc
+	exitFunc = func(int) { *steps = append(*steps, "exit") }
+	t.Cleanup(func() { exitFunc = original })
+}
+
+func TestFlushOnFatalRecordsAndFlushesBeforeExit(t *testing.T) {
+	var steps []string
+	replaceExit(t, &steps)
+
+	recorder := &flushRecorder{
+		SpanRecorder: tracetest.NewSpanRecorder(),
+		flushed:      func() { steps = append(steps, "flush") },
+	}
+	tracer := NewTracerFromProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), "test")
+	logger, buf := newTestLogger(t, nil)
+
+	ctx, _ := tracer.Start(context.Background(), "work")
+	logger.FlushOnFatal(tracer).Fatal(ctx, "out of disk")
+
+	if len(steps) != 2 || steps[0] != "flush" || steps[1] != "exit" {
+		t.Errorf("steps = %v, want flush then exit", steps)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d ended spans, want the active span ended", len(spans))
+	}
+	if status := spans[0].Status(); status.Code != codes.Error || status.Description != "out of disk" {
+		t.Errorf("span status = %+v, want the fatal message as an error", status)
+	}
+	if events := spans[0].Events(); len(events) != 1 || events[0].Name != "fatal" {
+		t.Errorf("span events = %v, want a fatal event", events)
+	}
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["level"] != "fatal" {
+		t.Errorf("entries = %v, want the fatal entry written", entries)
+	}
+}
+
+func TestFlushOnFatalWithoutTracer(t *testing.T) {
+	var steps []string
+	replaceExit(t, &steps)
+	logger, buf := newTestLogger(t, nil)
+
+	logger.FlushOnFatal(nil).Fatal(context.Background(), "out of disk")
+
+	if len(steps) != 1 || steps[0] != "exit" {
+		t.Errorf("steps = %v, want exit", steps)
+	}
+	if entries := logEntries(t, buf); len(entries) != 1 {
+		t.Errorf("got %d entries, want the fatal entry written", len(entries))
+	}
+}
+
+func TestFlushOnFatalRecordsCheckedEntries(t *testing.T) {
+	var steps []string
+	replaceExit(t, &steps)
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracerFromProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), "test")
+	logger, buf := newTestLogger(t, nil)
+
+	ctx, _ := tracer.Start(context.Background(), "work")
+	ce := logger.FlushOnFatal(tracer).Check(ctx, FatalLevel, "out of disk")
+	if ce == nil {
+		t.Fatal("Check(FatalLevel) = nil, want an entry")
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Fatalf("got %d ended spans before the entry was written, want 0", len(spans))
+	}
+	ce.Write()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("ended spans = %v, want the active span ended with an error", spans)
+	}
+	if len(steps) != 1 || steps[0] != "exit" {
+		t.Errorf("steps = %v, want exit", steps)
+	}
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["trace_id"] != spans[0].SpanContext().TraceID().String() {
+		t.Errorf("entries = %v, want the fatal entry written with its trace", entries)
+	}
+}
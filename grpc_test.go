@@ -0,0 +1,38 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCMetadataRoundTrip(t *testing.T) {
+	useTraceContextPropagator(t)
+	tracer, _ := NewTestTracer()
+
+	ctx, span := tracer.Start(context.Background(), "client")
+	defer span.End()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "token")
+
+	outgoing := InjectIntoGRPCMetadata(ctx)
+	md, _ := metadata.FromOutgoingContext(outgoing)
+	if md.Get("authorization")[0] != "token" {
+		t.Error("existing outgoing metadata was lost")
+	}
+
+	// The server receives the client's outgoing metadata as incoming metadata
+	incoming := metadata.NewIncomingContext(context.Background(), md)
+	got := trace.SpanContextFromContext(ExtractFromGRPCMetadata(incoming))
+	if !got.IsRemote() || got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("extracted span context %v, want the remote client span %v", got, span.SpanContext())
+	}
+}
+
+func TestExtractFromGRPCMetadataWithoutMetadata(t *testing.T) {
+	ctx := context.Background()
+	if got := ExtractFromGRPCMetadata(ctx); got != ctx {
+		t.Error("context without metadata was replaced")
+	}
+}
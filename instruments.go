@@ -0,0 +1,48 @@
+package observability
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InstrumentKind selects the type of instrument an InstrumentSpec creates
+type InstrumentKind int
+
+const (
+	CounterInstrument InstrumentKind = iota
+	UpDownCounterInstrument
+	HistogramInstrument
+)
+
+// InstrumentSpec declares an instrument to create up front with Register
+type InstrumentSpec struct {
+	Kind        InstrumentKind
+	Name        string
+	Description string
+	Unit        string
+	// Buckets sets explicit histogram bucket boundaries; empty uses the SDK defaults
+	Buckets []float64
+}
+
+// Register creates every instrument in specs so misconfigurations surface at startup
+// rather than on first use. It attempts all specs and returns the combined errors.
+func (m *Metrics) Register(specs []InstrumentSpec) error {
+	var errs []error
+	for _, spec := range specs {
+		var err error
+		switch spec.Kind {
+		case CounterInstrument:
+			_, err = m.createCounter(spec.Name, spec.Description, spec.Unit)
+		case UpDownCounterInstrument:
+			_, err = m.createUpDownCounter(spec.Name, spec.Description, spec.Unit)
+		case HistogramInstrument:
+			_, err = m.createHistogram(spec.Name, spec.Description, spec.Unit, spec.Buckets)
+		default:
+			err = fmt.Errorf("unknown instrument kind %d", spec.Kind)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instrument %s: %w", spec.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
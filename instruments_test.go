@@ -0,0 +1,76 @@
+package observability
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRegister(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	ctx := context.Background()
+
+	err := m.Register([]InstrumentSpec{
+		{Kind: CounterInstrument, Name: "orders", Description: "Orders placed", Unit: "{order}"},
+		{Kind: UpDownCounterInstrument, Name: "carts_open", Description: "Open carts"},
+		{Kind: HistogramInstrument, Name: "checkout_duration", Description: "Checkout time", Unit: "s", Buckets: []float64{0.1, 1, 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.RegisteredInstruments(), []string{"carts_open", "checkout_duration", "orders"}; !slices.Equal(got, want) {
+		t.Fatalf("RegisteredInstruments() = %v, want %v", got, want)
+	}
+
+	// Recording by name uses the registered instruments rather than lazily creating new ones
+	if err := m.IncrementCounter(ctx, "orders", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordHistogram(ctx, "checkout_duration", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	carts, err := m.CreateUpDownCounter("carts_open", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	carts.Add(ctx, 2)
+
+	metrics := collect(t, reader)
+	for name, want := range map[string][2]string{
+		"orders":            {"Orders placed", "{order}"},
+		"carts_open":        {"Open carts", ""},
+		"checkout_duration": {"Checkout time", "s"},
+	} {
+		if got := metrics[name]; got.Description != want[0] || got.Unit != want[1] {
+			t.Errorf("%s description %q unit %q, want %q and %q", name, got.Description, got.Unit, want[0], want[1])
+		}
+	}
+	histogram := metrics["checkout_duration"].Data.(metricdata.Histogram[float64])
+	if bounds := histogram.DataPoints[0].Bounds; !slices.Equal(bounds, []float64{0.1, 1, 10}) {
+		t.Errorf("bounds = %v, want the spec's buckets", bounds)
+	}
+}
+
+func TestRegisterReportsEveryFailure(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{})
+
+	err := m.Register([]InstrumentSpec{
+		{Kind: InstrumentKind(42), Name: "mystery"},
+		{Kind: CounterInstrument, Name: "orders"},
+		{Kind: HistogramInstrument, Name: "bad name!"},
+	})
+	if err == nil {
+		t.Fatal("Register() succeeded, want the invalid specs reported")
+	}
+	for _, name := range []string{"mystery", "bad name!"} {
+		if !strings.Contains(err.Error(), "instrument "+name+":") {
+			t.Errorf("Register() = %v, want an error for %s", err, name)
+		}
+	}
+	if got := m.RegisteredInstruments(); !slices.Equal(got, []string{"orders"}) {
+		t.Errorf("RegisteredInstruments() = %v, want the valid spec still registered", got)
+	}
+}
@@ -2,56 +2,81 @@ package observability
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"sort"
+	"syscall"
+	"time"
 
+	otellog "go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// auditLevel is the fixed level audit entries are written at
+const auditLevel = zapcore.InfoLevel
+
+// defaultLogSamplingTick is the log sampling interval when none is configured
+const defaultLogSamplingTick = time.Second
+
+// logErrorsMetric counts Error and Fatal log entries
+const logErrorsMetric = "app_log_errors_total"
+
+// LoggerInterface is the logging surface of Logger, for code that wants to accept a mock.
+// Methods deriving a logger return *Logger, so *Logger implements it; Child derives a
+// LoggerInterface for code that must keep working with mocks.
+type LoggerInterface interface {
+	Debug(ctx context.Context, msg string, fields ...zap.Field)
+	Info(ctx context.Context, msg string, fields ...zap.Field)
+	Warn(ctx context.Context, msg string, fields ...zap.Field)
+	Error(ctx context.Context, msg string, fields ...zap.Field)
+	Fatal(ctx context.Context, msg string, fields ...zap.Field)
+	Check(ctx context.Context, level LogLevel, msg string) *zapcore.CheckedEntry
+	Audit(ctx context.Context, action string, fields ...zap.Field)
+	With(fields ...zap.Field) *Logger
+	WithFields(fields map[string]interface{}) *Logger
+	WithTraceID(traceID, spanID string) *Logger
+	WithNamespace(name string) *Logger
+	Named(name string) *Logger
+	WithMetrics(metrics *Metrics) *Logger
+	FlushOnFatal(tracer *Tracer) *Logger
+	Child(name string, fields ...zap.Field) LoggerInterface
+	StdLogWriter(level LogLevel) io.Writer
+	RedirectStdLog() func()
+	Sync() error
+}
+
+var _ LoggerInterface = (*Logger)(nil)
+
 // Logger is a wrapper around zap.Logger with context-aware methods
 type Logger struct {
 	logger *zap.Logger
+	audit  *zap.Logger
+	// fatalSpans records fatal entries on the active span, see FlushOnFatal
+	fatalSpans bool
 }
 
-// NewLogger creates a new logger from configuration
-func NewLogger(config *LogConfig) (*Logger, error) {
-	var logLevel zapcore.Level
-	switch config.Level {
-	case DebugLevel:
-		logLevel = zapcore.DebugLevel
-	case InfoLevel:
-		logLevel = zapcore.InfoLevel
-	case WarnLevel:
-		logLevel = zapcore.WarnLevel
-	case ErrorLevel:
-		logLevel = zapcore.ErrorLevel
-	case FatalLevel:
-		logLevel = zapcore.FatalLevel
-	default:
-		logLevel = zapcore.InfoLevel
+// NewLogger creates a new logger from configuration. Any zap options are applied
+// after the package defaults so they can extend or override them.
+func NewLogger(config *LogConfig, opts ...zap.Option) (*Logger, error) {
+	logLevel := toZapLevel(config.Level)
+
+	outputs, err := openOutputs(config.OutputPaths)
+	if err != nil {
+		return nil, err
 	}
 
-	var outputs []io.Writer
-	for _, path := range config.OutputPaths {
-		if path == "stdout" {
-			outputs = append(outputs, os.Stdout)
-		} else if path == "stderr" {
-			outputs = append(outputs, os.Stderr)
-		} else {
-			// Open file for writing
-			file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return nil, err
-			}
-			outputs = append(outputs, file)
-		}
+	// Include the caller-provided writer alongside the configured paths
+	if config.Writer != nil {
+		outputs = append(outputs, config.Writer)
 	}
 
 	// Use default output if none specified
 	if len(outputs) == 0 {
-		outputs = append(outputs, os.Stdout)
+		outputs = append(outputs, consoleOutput{os.Stdout})
 	}
 
 	encoderConfig := zapcore.EncoderConfig{
@@ -68,6 +93,10 @@ func NewLogger(config *LogConfig) (*Logger, error) {
 		EncodeDuration: zapcore.MillisDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
+	config.EncoderKeys.apply(&encoderConfig)
+	if config.Color && config.Development && config.Format == ConsoleFormat {
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
 
 	var encoder zapcore.Encoder
 	if config.Format == JSONFormat {
@@ -76,43 +105,304 @@ func NewLogger(config *LogConfig) (*Logger, error) {
 		encoder = zapcore.NewConsoleEncoder(encoderConfig)
 	}
 
-	var syncer zapcore.WriteSyncer
-	if len(outputs) == 1 {
-		syncer = zapcore.AddSync(outputs[0])
-	} else {
-		syncers := make([]zapcore.WriteSyncer, len(outputs))
-		for i, output := range outputs {
-			syncers[i] = zapcore.AddSync(output)
+	// Decorating wrappers are applied per leaf core because a tee writes to
+	// every core it holds without re-checking levels
+	newCore := func(syncer zapcore.WriteSyncer, level zapcore.LevelEnabler) zapcore.Core {
+		core := zapcore.NewCore(encoder, syncer, level)
+		if config.IncludeGoroutineID {
+			core = &goroutineCore{Core: core}
+		}
+		if config.StructuredStacktrace {
+			core = &structuredStackCore{Core: core, key: encoderConfig.StacktraceKey}
+		}
+		if config.DedupWindow > 0 {
+			core = newDedupCore(core, config.DedupWindow)
+		}
+		return core
+	}
+
+	core := newCore(newWriteSyncer(outputs), logLevel)
+
+	// Route entries at or above each configured level to their dedicated outputs as well
+	if len(config.LevelOutputs) > 0 {
+		levels := make([]LogLevel, 0, len(config.LevelOutputs))
+		for level := range config.LevelOutputs {
+			levels = append(levels, level)
+		}
+		sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+		cores := []zapcore.Core{core}
+		for _, level := range levels {
+			routeOutputs, err := openOutputs(config.LevelOutputs[level])
+			if err != nil {
+				return nil, err
+			}
+			if len(routeOutputs) == 0 {
+				continue
+			}
+
+			minLevel := toZapLevel(level)
+			if minLevel < logLevel {
+				minLevel = logLevel
+			}
+			cores = append(cores, newCore(newWriteSyncer(routeOutputs), minLevel))
 		}
-		syncer = zapcore.NewMultiWriteSyncer(syncers...)
+		core = zapcore.NewTee(cores...)
 	}
 
-	core := zapcore.NewCore(encoder, syncer, logLevel)
+	// Sample repeated entries, letting the first with each message through unsampled
+	if config.Sampling != nil {
+		tick := config.Sampling.Tick
+		if tick <= 0 {
+			tick = defaultLogSamplingTick
+		}
+		sampled := zapcore.NewSamplerWithOptions(core, tick, config.Sampling.Initial, config.Sampling.Thereafter)
+		core = newFirstSeenCore(sampled, core)
+	}
 
 	// Create logger with caller and stacktrace
-	var logger *zap.Logger
+	options := []zap.Option{zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)}
 	if config.Development {
-		logger = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.Development())
-	} else {
-		logger = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+		options = append(options, zap.Development())
 	}
+	if len(config.Hooks) > 0 {
+		options = append(options, zap.Hooks(recoverHooks(config.Hooks)...))
+	}
+	if config.IncludeHostFields {
+		options = append(options, zap.Fields(hostFields()...))
+	}
+	options = append(options, opts...)
+	logger := zap.New(core, options...)
 
-	return &Logger{logger: logger}, nil
+	// Audit entries bypass level filtering, going to their own outputs or else the main ones
+	auditOutputs := outputs
+	if len(config.AuditOutputPaths) > 0 {
+		auditOutputs, err = openOutputs(config.AuditOutputPaths)
+		if err != nil {
+			return nil, err
+		}
+	}
+	audit := zap.New(zapcore.NewCore(encoder, newWriteSyncer(auditOutputs), auditLevel), zap.AddCaller())
+
+	return &Logger{logger: logger, audit: audit}, nil
+}
+
+// apply overrides the keys of the encoder configuration with the non-empty keys
+func (k EncoderKeys) apply(config *zapcore.EncoderConfig) {
+	overrides := []struct {
+		key    string
+		target *string
+	}{
+		{k.MessageKey, &config.MessageKey},
+		{k.TimeKey, &config.TimeKey},
+		{k.LevelKey, &config.LevelKey},
+		{k.NameKey, &config.NameKey},
+		{k.CallerKey, &config.CallerKey},
+		{k.StacktraceKey, &config.StacktraceKey},
+	}
+	for _, override := range overrides {
+		if override.key != "" {
+			*override.target = override.key
+		}
+	}
+}
+
+// recoverHooks wraps entry hooks so a panicking hook is reported as an error instead of crashing the caller
+func recoverHooks(hooks []func(zapcore.Entry) error) []func(zapcore.Entry) error {
+	wrapped := make([]func(zapcore.Entry) error, len(hooks))
+	for i, hook := range hooks {
+		wrapped[i] = func(entry zapcore.Entry) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("log hook panicked: %v", r)
+				}
+			}()
+			return hook(entry)
+		}
+	}
+	return wrapped
+}
+
+// hostFields returns the hostname and pid fields, omitting the hostname if it cannot be resolved
+func hostFields() []zap.Field {
+	fields := []zap.Field{zap.Int("pid", os.Getpid())}
+	if hostname, err := os.Hostname(); err == nil {
+		fields = append([]zap.Field{zap.String("hostname", hostname)}, fields...)
+	}
+	return fields
+}
+
+// toZapLevel converts a LogLevel to the equivalent zap level
+func toZapLevel(level LogLevel) zapcore.Level {
+	switch level {
+	case DebugLevel:
+		return zapcore.DebugLevel
+	case InfoLevel:
+		return zapcore.InfoLevel
+	case WarnLevel:
+		return zapcore.WarnLevel
+	case ErrorLevel:
+		return zapcore.ErrorLevel
+	case FatalLevel:
+		return zapcore.FatalLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// ToOTELSeverity converts a LogLevel to the equivalent OpenTelemetry log severity
+func ToOTELSeverity(level LogLevel) otellog.Severity {
+	switch level {
+	case DebugLevel:
+		return otellog.SeverityDebug
+	case InfoLevel:
+		return otellog.SeverityInfo
+	case WarnLevel:
+		return otellog.SeverityWarn
+	case ErrorLevel:
+		return otellog.SeverityError
+	case FatalLevel:
+		return otellog.SeverityFatal
+	default:
+		return otellog.SeverityInfo
+	}
+}
+
+// openOutputs resolves output paths to writers, opening files as needed
+func openOutputs(paths []string) ([]io.Writer, error) {
+	var outputs []io.Writer
+	for _, path := range paths {
+		if path == "stdout" {
+			outputs = append(outputs, consoleOutput{os.Stdout})
+		} else if path == "stderr" {
+			outputs = append(outputs, consoleOutput{os.Stderr})
+		} else {
+			// Open file for writing
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, file)
+		}
+	}
+	return outputs, nil
+}
+
+// consoleOutput is stdout or stderr, which cannot be synced when attached to a
+// terminal or pipe; the resulting errors are ignored so they don't mask real ones
+type consoleOutput struct {
+	*os.File
+}
+
+// Sync flushes the file, ignoring the errors returned for unsyncable files
+func (o consoleOutput) Sync() error {
+	err := o.File.Sync()
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+		return nil
+	}
+	return err
+}
+
+// newWriteSyncer combines writers into a single write syncer
+func newWriteSyncer(outputs []io.Writer) zapcore.WriteSyncer {
+	if len(outputs) == 1 {
+		return zapcore.AddSync(outputs[0])
+	}
+
+	syncers := make([]zapcore.WriteSyncer, len(outputs))
+	for i, output := range outputs {
+		syncers[i] = zapcore.AddSync(output)
+	}
+	return zapcore.NewMultiWriteSyncer(syncers...)
+}
+
+// derive returns a copy of the Logger with fn applied to its zap logger and, so audit
+// entries carry the same context, to its audit logger
+func (l *Logger) derive(fn func(*zap.Logger) *zap.Logger) *Logger {
+	c := *l
+	c.logger = fn(l.logger)
+	if l.audit != nil {
+		c.audit = fn(l.audit)
+	}
+	return &c
+}
+
+// with returns a copy of the Logger with fields added to every entry
+func (l *Logger) with(fields ...zap.Field) *Logger {
+	return l.derive(func(logger *zap.Logger) *zap.Logger { return logger.With(fields...) })
 }
 
 // With adds structured context to the Logger
 func (l *Logger) With(fields ...zap.Field) *Logger {
 	// Need to preserve the same caller skip behavior in the new logger instance
-	return &Logger{logger: l.logger.With(fields...)}
+	return l.with(fields...)
 }
 
-// WithFields adds fields to the logger
+// WithFields adds fields to the logger, ordered by key so output is deterministic
 func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	zapFields := make([]zap.Field, 0, len(fields))
-	for k, v := range fields {
-		zapFields = append(zapFields, zap.Any(k, v))
+	for _, k := range keys {
+		zapFields = append(zapFields, zap.Any(k, fields[k]))
+	}
+	return l.with(zapFields...)
+}
+
+// WithTraceID attaches an externally supplied trace and span ID to the logger, e.g.
+// one received from another system without a span context. IDs that are not
+// valid lowercase hex of the right length are skipped.
+func (l *Logger) WithTraceID(traceID, spanID string) *Logger {
+	var fields []zap.Field
+	if id, err := trace.TraceIDFromHex(traceID); err == nil {
+		fields = append(fields, zap.String("trace_id", id.String()))
+	}
+	if id, err := trace.SpanIDFromHex(spanID); err == nil {
+		fields = append(fields, zap.String("span_id", id.String()))
 	}
-	return &Logger{logger: l.logger.With(zapFields...)}
+	return l.with(fields...)
+}
+
+// WithNamespace returns a logger whose subsequently added fields, both from With and
+// from logging calls, are nested under the name key. This includes the trace context
+// fields added from the context.
+func (l *Logger) WithNamespace(name string) *Logger {
+	return l.with(zap.Namespace(name))
+}
+
+// Named adds a sub-scope to the logger's name
+func (l *Logger) Named(name string) *Logger {
+	return l.derive(func(logger *zap.Logger) *zap.Logger { return logger.Named(name) })
+}
+
+// WithMetrics returns a logger that counts Error and Fatal entries in the
+// app_log_errors_total counter, tagged with the logger's name as component.
+// Only entries that are actually written count, not those filtered or sampled out.
+func (l *Logger) WithMetrics(metrics *Metrics) *Logger {
+	count := func(entry zapcore.Entry) error {
+		if entry.Level >= zapcore.ErrorLevel {
+			// IncrementCounter reports its own failures
+			_ = metrics.IncrementCounter(context.Background(), logErrorsMetric, 1, Component(entry.LoggerName))
+		}
+		return nil
+	}
+	c := *l
+	c.logger = l.logger.WithOptions(zap.Hooks(count))
+	return &c
+}
+
+// Child returns a logger named after name beneath this one, or keeping its name if name
+// is empty, with fields added to every entry
+func (l *Logger) Child(name string, fields ...zap.Field) LoggerInterface {
+	c := l
+	if name != "" {
+		c = c.Named(name)
+	}
+	return c.with(fields...)
 }
 
 // getSkippedLogger returns a logger with the caller skip set to skip this file's methods
@@ -123,36 +413,92 @@ func (l *Logger) getSkippedLogger() *zap.Logger {
 
 // Debug logs a debug message with trace context
 func (l *Logger) Debug(ctx context.Context, msg string, fields ...zap.Field) {
-	fields = append(fields, extractTraceFields(ctx)...)
+	fields = append(fields, extractContextFields(ctx)...)
 	l.getSkippedLogger().Debug(msg, fields...)
 }
 
 // Info logs an info message with trace context
 func (l *Logger) Info(ctx context.Context, msg string, fields ...zap.Field) {
-	fields = append(fields, extractTraceFields(ctx)...)
+	fields = append(fields, extractContextFields(ctx)...)
 	l.getSkippedLogger().Info(msg, fields...)
 }
 
 // Warn logs a warning message with trace context
 func (l *Logger) Warn(ctx context.Context, msg string, fields ...zap.Field) {
-	fields = append(fields, extractTraceFields(ctx)...)
+	fields = append(fields, extractContextFields(ctx)...)
 	l.getSkippedLogger().Warn(msg, fields...)
 }
 
 // Error logs an error message with trace context
 func (l *Logger) Error(ctx context.Context, msg string, fields ...zap.Field) {
-	fields = append(fields, extractTraceFields(ctx)...)
+	fields = append(fields, extractContextFields(ctx)...)
 	l.getSkippedLogger().Error(msg, fields...)
 }
 
 // Fatal logs a fatal message with trace context and exits
 func (l *Logger) Fatal(ctx context.Context, msg string, fields ...zap.Field) {
-	fields = append(fields, extractTraceFields(ctx)...)
+	l.recordFatal(ctx, msg)
+	fields = append(fields, extractContextFields(ctx)...)
 	l.getSkippedLogger().Fatal(msg, fields...)
 }
 
-// extractTraceFields extracts trace information from context
-func extractTraceFields(ctx context.Context) []zap.Field {
+// Audit records who did what to the audit sink. Audit entries are written at a fixed
+// level regardless of the configured log level and are never sampled.
+func (l *Logger) Audit(ctx context.Context, action string, fields ...zap.Field) {
+	l.writeAudit(ctx, 2, action, fields...)
+}
+
+// writeAudit writes an audit entry, skipping the given number of frames, including its own, for caller reporting
+func (l *Logger) writeAudit(ctx context.Context, skip int, action string, fields ...zap.Field) {
+	audit := l.audit
+	if audit == nil {
+		// Loggers not built by NewLogger have no dedicated sink
+		audit = l.logger
+	}
+	// Copy so the flag isn't appended into the caller's backing array
+	fields = append(append([]zap.Field{}, fields...), zap.Bool("audit", true))
+	audit.WithOptions(zap.AddCallerSkip(skip)).Info(action, append(fields, extractContextFields(ctx)...)...)
+}
+
+// Check returns a CheckedEntry if logging at the level is enabled, or nil otherwise,
+// so hot paths can skip building fields. Trace context from ctx is attached to the entry
+// when it is written, and a written fatal entry is recorded like Fatal.
+func (l *Logger) Check(ctx context.Context, level LogLevel, msg string) *zapcore.CheckedEntry {
+	zapLevel := toZapLevel(level)
+	if !l.logger.Core().Enabled(zapLevel) {
+		return nil
+	}
+
+	bindContext := zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &contextCore{Core: core, ctx: ctx, fatal: l.recordFatal}
+	})
+	return l.logger.WithOptions(zap.AddCallerSkip(1), bindContext).Check(zapLevel, msg)
+}
+
+// log writes an entry at the given level, skipping the given number of wrapper frames for caller reporting
+func (l *Logger) log(ctx context.Context, level LogLevel, skip int, msg string, fields ...zap.Field) {
+	if level == FatalLevel {
+		l.recordFatal(ctx, msg)
+	}
+	if ce := l.logger.WithOptions(zap.AddCallerSkip(skip)).Check(toZapLevel(level), msg); ce != nil {
+		ce.Write(append(fields, extractContextFields(ctx)...)...)
+	}
+}
+
+// contextFieldsKey is the context key for log fields bound to a context
+type contextFieldsKey struct{}
+
+// ContextWithFields returns a copy of ctx carrying fields that are added to every entry logged with it
+func ContextWithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	existing, _ := ctx.Value(contextFieldsKey{}).([]zap.Field)
+	merged := make([]zap.Field, 0, len(existing)+len(fields))
+	merged = append(merged, existing...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, contextFieldsKey{}, merged)
+}
+
+// extractContextFields extracts trace information and bound fields from context
+func extractContextFields(ctx context.Context) []zap.Field {
 	fields := []zap.Field{}
 
 	spanCtx := trace.SpanContextFromContext(ctx)
@@ -161,10 +507,74 @@ func extractTraceFields(ctx context.Context) []zap.Field {
 		fields = append(fields, zap.String("span_id", spanCtx.SpanID().String()))
 	}
 
+	if bound, ok := ctx.Value(contextFieldsKey{}).([]zap.Field); ok {
+		fields = append(fields, bound...)
+	}
+
 	return fields
 }
 
+// loggerKey is the context key for a request-scoped logger
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying the logger
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger bound to ctx, or a logger that discards everything if there is none
+func LoggerFromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return logger
+	}
+	return &Logger{logger: zap.NewNop()}
+}
+
+// StdLogWriter returns a writer that logs each line written to it as an entry at the
+// given level, e.g. to back a standard library *log.Logger used by a dependency.
+// Unknown levels log at info.
+func (l *Logger) StdLogWriter(level LogLevel) io.Writer {
+	stdLog, err := zap.NewStdLogAt(l.logger, toZapLevel(level))
+	if err != nil {
+		stdLog = zap.NewStdLog(l.logger)
+	}
+	return stdLog.Writer()
+}
+
+// RedirectStdLog routes output of the standard library's global logger through this
+// logger at info level until the returned func is called to restore it
+func (l *Logger) RedirectStdLog() func() {
+	return zap.RedirectStdLog(l.logger)
+}
+
 // Sync flushes any buffered log entries
 func (l *Logger) Sync() error {
-	return l.logger.Sync()
+	if l.audit == nil {
+		return l.logger.Sync()
+	}
+	return errors.Join(l.logger.Sync(), l.audit.Sync())
+}
+
+// LazyField returns a field whose value is computed by fn only when the entry is
+// actually encoded, so expensive values cost nothing for disabled levels
+func LazyField(key string, fn func() interface{}) zap.Field {
+	return zap.Inline(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		return enc.AddReflected(key, fn())
+	}))
+}
+
+// CodedError returns a field logging err under "error" and, when err or any error
+// it wraps has a Code() string method, its code under "error_code"
+func CodedError(err error) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.Inline(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("error", err.Error())
+		var coded interface{ Code() string }
+		if errors.As(err, &coded) {
+			enc.AddString("error_code", coded.Code())
+		}
+		return nil
+	}))
 }
@@ -0,0 +1,596 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of asynchronous cores
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newTestLogger creates a JSON logger writing to a buffer; config may be nil
+func newTestLogger(t *testing.T, config *LogConfig) (*Logger, *syncBuffer) {
+	t.Helper()
+	if config == nil {
+		config = &LogConfig{}
+	}
+	buf := &syncBuffer{}
+	config.Writer = buf
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	return logger, buf
+}
+
+// logEntries decodes the JSON entries written to buf
+func logEntries(t *testing.T, buf *syncBuffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLoggerInterfaceReportsCaller(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+
+	var iface LoggerInterface = logger
+	iface.Child("worker").Info(context.Background(), "hello")
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if caller, _ := entries[0]["caller"].(string); !strings.Contains(caller, "logger_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+	if entries[0]["logger"] != "worker" {
+		t.Errorf("logger = %v, want the child's name", entries[0]["logger"])
+	}
+}
+
+func TestLoggerWritesToWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var buf bytes.Buffer
+	logger, err := NewLogger(&LogConfig{Format: JSONFormat, OutputPaths: []string{path}, Writer: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info(context.Background(), "order placed", zap.String("order", "o-1"), zap.Int("items", 3))
+	_ = logger.Sync()
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	if entry["message"] != "order placed" || entry["level"] != "info" {
+		t.Errorf("entry = %v, want the info message", entry)
+	}
+	if entry["order"] != "o-1" || entry["items"] != float64(3) {
+		t.Errorf("entry = %v, want the order and items fields", entry)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "order placed") {
+		t.Errorf("output path got %q, want the entry alongside the writer", data)
+	}
+}
+
+func TestLoggerRoutesLevelsToOutputs(t *testing.T) {
+	errorsPath := filepath.Join(t.TempDir(), "errors.log")
+	logger, general := newTestLogger(t, &LogConfig{
+		Level:        DebugLevel,
+		LevelOutputs: map[LogLevel][]string{ErrorLevel: {errorsPath}},
+	})
+
+	ctx := context.Background()
+	logger.Debug(ctx, "cache miss")
+	logger.Error(ctx, "payment failed")
+	_ = logger.Sync()
+
+	if out := general.String(); !strings.Contains(out, "cache miss") || !strings.Contains(out, "payment failed") {
+		t.Errorf("general output = %q, want both entries", out)
+	}
+	data, err := os.ReadFile(errorsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(data); !strings.Contains(out, "payment failed") || strings.Contains(out, "cache miss") {
+		t.Errorf("error output = %q, want only the error entry", out)
+	}
+}
+
+func TestLoggerHooksObserveEntries(t *testing.T) {
+	var seen []zapcore.Entry
+	logger, _ := newTestLogger(t, &LogConfig{Hooks: []func(zapcore.Entry) error{
+		func(entry zapcore.Entry) error {
+			seen = append(seen, entry)
+			return nil
+		},
+		func(zapcore.Entry) error { panic("webhook down") },
+	}})
+
+	logger.Error(context.Background(), "payment failed")
+
+	if len(seen) != 1 {
+		t.Fatalf("hook saw %d entries, want 1", len(seen))
+	}
+	if seen[0].Message != "payment failed" || seen[0].Level != zapcore.ErrorLevel {
+		t.Errorf("hook saw %q at %v, want the error entry", seen[0].Message, seen[0].Level)
+	}
+}
+
+func TestNewLoggerAppliesOptions(t *testing.T) {
+	buf := &syncBuffer{}
+	logger, err := NewLogger(&LogConfig{Writer: buf}, zap.Fields(zap.String("region", "eu-west-1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info(context.Background(), "started")
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["region"] != "eu-west-1" {
+		t.Errorf("entries = %v, want the injected field", entries)
+	}
+}
+
+func TestLoggerCountsErrors(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	logger, _ := newTestLogger(t, nil)
+	logger = logger.Named("billing").WithMetrics(m)
+
+	ctx := context.Background()
+	logger.Error(ctx, "charge failed")
+	logger.Warn(ctx, "retrying")
+	logger.Error(ctx, "charge failed again")
+
+	counter, ok := collect(t, reader)[logErrorsMetric].Data.(metricdata.Sum[int64])
+	if !ok || len(counter.DataPoints) != 1 {
+		t.Fatalf("%s points = %+v, want one series", logErrorsMetric, counter.DataPoints)
+	}
+	point := counter.DataPoints[0]
+	if point.Value != 2 {
+		t.Errorf("counted %d errors, want 2", point.Value)
+	}
+	if v, _ := point.Attributes.Value(ComponentKey); v.AsString() != "billing" {
+		t.Errorf("component = %q, want the logger name", v.AsString())
+	}
+}
+
+func TestLoggerCountsOnlyWrittenErrors(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	logger, buf := newTestLogger(t, &LogConfig{Level: FatalLevel})
+	logger = logger.WithMetrics(m)
+
+	logger.Error(context.Background(), "below the configured level")
+
+	if buf.String() != "" {
+		t.Fatalf("output = %q, want the error filtered", buf.String())
+	}
+	if _, ok := collect(t, reader)[logErrorsMetric]; ok {
+		t.Errorf("%s recorded a filtered entry", logErrorsMetric)
+	}
+}
+
+func TestWithFieldsOrdersKeys(t *testing.T) {
+	fields := map[string]interface{}{"zone": "b", "attempt": 2, "merchant": "m-1", "currency": "EUR", "batch": true}
+	logger, buf := newTestLogger(t, nil)
+
+	for i := 0; i < 2; i++ {
+		logger.WithFields(fields).Info(context.Background(), "settled")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	previous := -1
+	for _, key := range []string{"attempt", "batch", "currency", "merchant", "zone"} {
+		offset := strings.Index(lines[0], `"`+key+`"`)
+		if offset < previous {
+			t.Fatalf("fields are not ordered by key: %s", lines[0])
+		}
+		previous = offset
+	}
+	if body := func(line string) string { return line[strings.Index(line, `"message"`):] }; body(lines[0]) != body(lines[1]) {
+		t.Errorf("calls encoded differently:\n%s\n%s", lines[0], lines[1])
+	}
+}
+
+func TestLazyFieldOnlyEvaluatedWhenEncoded(t *testing.T) {
+	logger, buf := newTestLogger(t, &LogConfig{Level: InfoLevel})
+	calls := 0
+	field := LazyField("payload", func() interface{} {
+		calls++
+		return map[string]int{"items": 3}
+	})
+
+	logger.Debug(context.Background(), "dumping payload", field)
+	if calls != 0 {
+		t.Errorf("fn called %d times for a disabled level, want 0", calls)
+	}
+
+	logger.Info(context.Background(), "dumping payload", field)
+	if calls != 1 {
+		t.Errorf("fn called %d times for an enabled level, want 1", calls)
+	}
+	entries := logEntries(t, buf)
+	if payload, _ := entries[0]["payload"].(map[string]interface{}); payload["items"] != float64(3) {
+		t.Errorf("payload = %v, want the computed value", entries[0]["payload"])
+	}
+}
+
+func TestLoggerCheck(t *testing.T) {
+	ctx := ContextWithFields(context.Background(), zap.String("tenant", "acme"))
+	logger, buf := newTestLogger(t, &LogConfig{Level: InfoLevel})
+
+	if ce := logger.Check(ctx, DebugLevel, "cache probe"); ce != nil {
+		t.Errorf("Check(DebugLevel) = %v, want nil below the configured level", ce)
+	}
+	ce := logger.Check(ctx, InfoLevel, "cache miss")
+	if ce == nil {
+		t.Fatal("Check(InfoLevel) = nil, want an entry")
+	}
+	ce.Write(zap.String("key", "user:42"))
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if entries[0]["message"] != "cache miss" || entries[0]["key"] != "user:42" {
+		t.Errorf("entry = %v, want the checked message and field", entries[0])
+	}
+	if entries[0]["tenant"] != "acme" {
+		t.Errorf("tenant = %v, want the field bound to the context", entries[0]["tenant"])
+	}
+	if caller, _ := entries[0]["caller"].(string); !strings.Contains(caller, "logger_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+}
+
+func TestWithTraceID(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name      string
+		traceID   string
+		spanID    string
+		wantTrace interface{}
+		wantSpan  interface{}
+	}{
+		{"valid", traceID, spanID, traceID, spanID},
+		{"short trace ID", "4bf92f35", spanID, nil, spanID},
+		{"non-hex span ID", traceID, "not-a-span-id!!!", traceID, nil},
+		{"zero IDs", strings.Repeat("0", 32), strings.Repeat("0", 16), nil, nil},
+		{"empty", "", "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger(t, nil)
+			logger.WithTraceID(tt.traceID, tt.spanID).Info(context.Background(), "imported")
+
+			entry := logEntries(t, buf)[0]
+			if entry["trace_id"] != tt.wantTrace {
+				t.Errorf("trace_id = %v, want %v", entry["trace_id"], tt.wantTrace)
+			}
+			if entry["span_id"] != tt.wantSpan {
+				t.Errorf("span_id = %v, want %v", entry["span_id"], tt.wantSpan)
+			}
+		})
+	}
+}
+
+// codedError is an error exposing an application error code
+type codedError struct {
+	code string
+}
+
+func (e codedError) Error() string { return "request failed" }
+
+func (e codedError) Code() string { return e.code }
+
+func TestCodedError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantError interface{}
+		wantCode  interface{}
+	}{
+		{"coded", codedError{code: "E_QUOTA"}, "request failed", "E_QUOTA"},
+		{"wrapped coded", fmt.Errorf("charge: %w", codedError{code: "E_CARD"}), "charge: request failed", "E_CARD"},
+		{"plain", errors.New("disk full"), "disk full", nil},
+		{"nil", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger(t, nil)
+			logger.Error(context.Background(), "operation failed", CodedError(tt.err))
+
+			entry := logEntries(t, buf)[0]
+			if entry["error"] != tt.wantError {
+				t.Errorf("error = %v, want %v", entry["error"], tt.wantError)
+			}
+			if entry["error_code"] != tt.wantCode {
+				t.Errorf("error_code = %v, want %v", entry["error_code"], tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestIncludeHostFields(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("hostname unavailable: %v", err)
+	}
+	for _, include := range []bool{true, false} {
+		logger, buf := newTestLogger(t, &LogConfig{IncludeHostFields: include})
+		logger.Info(context.Background(), "started")
+		logger.Info(context.Background(), "ready")
+
+		for _, entry := range logEntries(t, buf) {
+			gotHostname, hasHostname := entry["hostname"]
+			gotPID, hasPID := entry["pid"]
+			if !include {
+				if hasHostname || hasPID {
+					t.Errorf("entry = %v, want no host fields unless enabled", entry)
+				}
+				continue
+			}
+			if gotHostname != hostname || gotPID != float64(os.Getpid()) {
+				t.Errorf("hostname %v pid %v, want %q and %d", gotHostname, gotPID, hostname, os.Getpid())
+			}
+		}
+	}
+}
+
+func TestToOTELSeverity(t *testing.T) {
+	tests := []struct {
+		level LogLevel
+		want  otellog.Severity
+	}{
+		{DebugLevel, otellog.SeverityDebug},
+		{InfoLevel, otellog.SeverityInfo},
+		{WarnLevel, otellog.SeverityWarn},
+		{ErrorLevel, otellog.SeverityError},
+		{FatalLevel, otellog.SeverityFatal},
+		{LogLevel(99), otellog.SeverityInfo},
+	}
+	for _, tt := range tests {
+		if got := ToOTELSeverity(tt.level); got != tt.want {
+			t.Errorf("ToOTELSeverity(%d) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestEncoderKeys(t *testing.T) {
+	for _, structured := range []bool{false, true} {
+		logger, buf := newTestLogger(t, &LogConfig{
+			EncoderKeys:          EncoderKeys{MessageKey: "msg", TimeKey: "ts", StacktraceKey: "trace"},
+			StructuredStacktrace: structured,
+		})
+		logger.Named("billing").Error(context.Background(), "charge failed")
+
+		entry := logEntries(t, buf)[0]
+		if entry["msg"] != "charge failed" {
+			t.Errorf("msg = %v, want the message", entry["msg"])
+		}
+		if _, ok := entry["ts"].(string); !ok {
+			t.Errorf("ts = %v, want the timestamp", entry["ts"])
+		}
+		if entry["trace"] == nil {
+			t.Errorf("trace = %v, want the stacktrace", entry["trace"])
+		}
+		// Keys that weren't overridden keep their defaults
+		if entry["level"] != "error" || entry["logger"] != "billing" || entry["caller"] == nil {
+			t.Errorf("entry = %v, want the default level, logger and caller keys", entry)
+		}
+		for _, key := range []string{"message", "timestamp", "stacktrace"} {
+			if _, ok := entry[key]; ok {
+				t.Errorf("entry has the default %q key, want it renamed", key)
+			}
+		}
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	tracer, _ := NewTestTracer()
+	ctx, span := tracer.Start(context.Background(), "request")
+	defer span.End()
+
+	logger.With(zap.String("component", "api")).
+		WithNamespace("http").
+		With(zap.String("method", "GET")).
+		Info(ctx, "served", zap.Int("status", 200))
+
+	entry := logEntries(t, buf)[0]
+	if entry["component"] != "api" || entry["message"] != "served" {
+		t.Errorf("entry = %v, want fields added before the namespace at the top level", entry)
+	}
+	http, ok := entry["http"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("http = %#v, want an object", entry["http"])
+	}
+	if http["method"] != "GET" || http["status"] != float64(200) {
+		t.Errorf("http = %v, want the fields added after the namespace", http)
+	}
+	if http["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("http = %v, want the trace context nested too", http)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+
+	restore := logger.RedirectStdLog()
+	log.Println("legacy client retrying")
+	restore()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	log.Println("after restore")
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want only the redirected line", len(entries))
+	}
+	if entries[0]["message"] != "legacy client retrying" || entries[0]["level"] != "info" {
+		t.Errorf("entry = %v, want the line at info", entries[0])
+	}
+	if caller, _ := entries[0]["caller"].(string); !strings.Contains(caller, "logger_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+}
+
+func TestStdLogWriter(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	w := logger.StdLogWriter(ErrorLevel)
+
+	log.New(w, "", 0).Printf("pool exhausted: %d waiting", 3)
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["message"] != "pool exhausted: 3 waiting" || entries[0]["level"] != "error" {
+		t.Errorf("entries = %v, want the line at error", entries)
+	}
+}
+
+func TestStdLogWriterUnknownLevel(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+
+	log.New(logger.StdLogWriter(LogLevel(99)), "", 0).Print("cache warmed")
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["level"] != "info" {
+		t.Errorf("entries = %v, want the line at info", entries)
+	}
+}
+
+func TestConsoleColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LogConfig
+		want   bool
+	}{
+		{"enabled", LogConfig{Format: ConsoleFormat, Development: true, Color: true}, true},
+		{"off by default", LogConfig{Format: ConsoleFormat, Development: true}, false},
+		{"production", LogConfig{Format: ConsoleFormat, Color: true}, false},
+		{"json", LogConfig{Format: JSONFormat, Development: true, Color: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger(t, &tt.config)
+			logger.Warn(context.Background(), "disk almost full")
+
+			if got := strings.Contains(buf.String(), "\x1b["); got != tt.want {
+				t.Errorf("output %q has ANSI codes = %v, want %v", buf.String(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsoleOutputSync(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if w.Sync() == nil {
+		t.Skip("pipes can be synced on this platform")
+	}
+	if err := (consoleOutput{w}).Sync(); err != nil {
+		t.Errorf("Sync() = %v, want the console sync error ignored", err)
+	}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if err := (consoleOutput{file}).Sync(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Sync() = %v, want real errors returned", err)
+	}
+}
+
+func TestLoggerSyncIgnoresConsoleErrors(t *testing.T) {
+	// Stand a pipe in for stdout, as when output is piped to a log collector
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	logger, err := NewLogger(&LogConfig{OutputPaths: []string{"stdout"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Sync(); err != nil {
+		t.Errorf("Sync() = %v, want nil for stdout", err)
+	}
+}
+
+func TestLoggerAudit(t *testing.T) {
+	logger, buf := newTestLogger(t, &LogConfig{Level: ErrorLevel})
+
+	logger.Audit(context.Background(), "role granted", zap.String("user", "alice"))
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the audit entry despite the level", len(entries))
+	}
+	if entries[0]["message"] != "role granted" || entries[0]["audit"] != true || entries[0]["user"] != "alice" {
+		t.Errorf("entry = %v, want the audit entry with its fields", entries[0])
+	}
+	if caller, _ := entries[0]["caller"].(string); !strings.Contains(caller, "logger_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+}
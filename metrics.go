@@ -2,36 +2,110 @@ package observability
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
+// Defaults for exponential histograms, matching the OpenTelemetry SDK
+const (
+	defaultExponentialMaxSize  = 160
+	defaultExponentialMaxScale = 20
+)
+
+// Clock provides the current time for duration measurements
+type Clock interface {
+	Now() time.Time
+}
+
+// wallClock is the Clock backed by the system time
+type wallClock struct{}
+
+// Now returns the current system time
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// MetricsInterface is the recording surface of Metrics, for code that wants to accept a mock
+type MetricsInterface interface {
+	CreateCounter(name, description string) (metric.Int64Counter, error)
+	IncrementCounter(ctx context.Context, name string, value int64, attrs ...attribute.KeyValue) error
+	RecordAndFlush(ctx context.Context, name string, value int64, attrs ...attribute.KeyValue) error
+	CreateUpDownCounter(name, description string) (metric.Int64UpDownCounter, error)
+	CreateHistogram(name, description, unit string) (metric.Float64Histogram, error)
+	RecordHistogram(ctx context.Context, name string, value float64, attrs ...attribute.KeyValue) error
+	RecordHistogramAt(ctx context.Context, name string, value float64, t time.Time, attrs ...attribute.KeyValue) error
+	CreateGauge(name, description string, callback func() float64) (metric.Float64ObservableGauge, error)
+	CreateGaugeWithAggregation(name, description string, aggregation sdkmetric.Aggregation, callback func() float64) (metric.Float64ObservableGauge, error)
+	RegisterBuildInfo(version, commit, date string) error
+	Register(specs []InstrumentSpec) error
+	RegisteredInstruments() []string
+	Snapshot(ctx context.Context) (map[string]interface{}, error)
+	MeasureDuration(ctx context.Context, name string, attrs ...attribute.KeyValue) func()
+	StartTimer(ctx context.Context, name string, attrs ...attribute.KeyValue) *Timer
+	NewWorkerMetrics(pool string, queueDepth func() float64) (*WorkerMetrics, error)
+	Scoped(prefix string, attrs ...attribute.KeyValue) MetricsInterface
+	HTTPMiddleware(next http.Handler) http.Handler
+	SetClock(clock Clock)
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+var _ MetricsInterface = (*Metrics)(nil)
+
 // Metrics is a wrapper for OpenTelemetry metrics
 type Metrics struct {
-	meter      metric.Meter
-	counters   map[string]metric.Int64Counter
-	gauges     map[string]metric.Float64ObservableGauge
-	histograms map[string]metric.Float64Histogram
-	shutdown   func() error
+	meter          metric.Meter
+	enabled        bool
+	mu             sync.RWMutex
+	counters       map[string]metric.Int64Counter
+	upDownCounters map[string]metric.Int64UpDownCounter
+	gauges         map[string]metric.Float64ObservableGauge
+	histograms     map[string]metric.Float64Histogram
+	// gaugeAggregations is read by the view while m.mu is held, so it has its own guard
+	gaugeAggregations *sync.Map
+	detachContext     bool
+	clock             Clock
+	sanitizer         AttributeSanitizer
+	flush             func(context.Context) error
+	shutdown          func() error
+	// prefix is prepended to instrument names and attrs are added to every measurement
+	prefix string
+	attrs  []attribute.KeyValue
+
+	// validateUnits rejects instruments whose unit is not a recognized UCUM code
+	validateUnits bool
+	// instruments counts the distinct instruments of m and its scoped metrics against
+	// MaxInstruments, or is nil if there is no cap
+	instruments *instrumentSet
+	// snapshot collects the values reported by Snapshot, or is nil if snapshots are disabled
+	snapshot *sdkmetric.ManualReader
 }
 
 // NewMetrics creates a new metrics collector
 func NewMetrics(ctx context.Context, config MetricsConfig) (*Metrics, error) {
 	if !config.Enabled {
-		return &Metrics{
-			counters:   make(map[string]metric.Int64Counter),
-			gauges:     make(map[string]metric.Float64ObservableGauge),
-			histograms: make(map[string]metric.Float64Histogram),
-			shutdown:   func() error { return nil },
-		}, nil
+		return newNoopMetrics(config), nil
+	}
+
+	if err := validateCompression(config.Compression); err != nil {
+		return nil, err
 	}
 
 	// Create resource with service information
@@ -40,40 +114,298 @@ func NewMetrics(ctx context.Context, config MetricsConfig) (*Metrics, error) {
 			semconv.ServiceNameKey.String(config.ServiceName),
 			semconv.ServiceVersionKey.String(config.ServiceVersion),
 			attribute.String("environment", config.Environment),
+			semconv.ServiceInstanceIDKey.String(resolveInstanceID(config.ServiceInstanceID)),
 		),
+		resource.WithAttributes(deploymentSlotAttributes(config.DeploymentSlot)...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create OTLP exporter
-	exporter, err := otlpmetricgrpc.New(ctx,
-		otlpmetricgrpc.WithEndpoint(config.Endpoint),
-		otlpmetricgrpc.WithInsecure(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	// Export to the injected exporter, or else to the collector unless only the console exporter was requested
+	var readers []sdkmetric.Reader
+	// No provider owns the readers created here until setup succeeds
+	shutdownReaders := func() {
+		for _, reader := range readers {
+			// The setup failure is the error worth reporting
+			_ = reader.Shutdown(ctx)
+		}
+	}
+	if config.MetricExporter != nil {
+		reader, err := newMetricReader(config, config.MetricExporter, config.SpoolDir)
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, reader)
+	} else if config.Endpoint != "" || !config.ConsoleExporter {
+		exporter, err := otlpmetricgrpc.New(ctx, metricExporterOptions(config)...)
+		if err != nil {
+			if config.FailOpen {
+				otel.Handle(fmt.Errorf("failed to create OTLP exporter, metrics disabled: %w", err))
+				return newNoopMetrics(config), nil
+			}
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		reader, err := newMetricReader(config, exporter, config.SpoolDir)
+		if err != nil {
+			_ = exporter.Shutdown(ctx)
+			return nil, err
+		}
+		readers = append(readers, reader)
+	}
+
+	if config.ConsoleExporter {
+		writer := config.ConsoleWriter
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err := stdoutmetric.New(stdoutmetric.WithWriter(writer), stdoutmetric.WithPrettyPrint())
+		if err != nil {
+			shutdownReaders()
+			return nil, fmt.Errorf("failed to create console exporter: %w", err)
+		}
+		// The console can't be unreachable, so it never spools
+		reader, err := newMetricReader(config, exporter, "")
+		if err != nil {
+			shutdownReaders()
+			return nil, err
+		}
+		readers = append(readers, reader)
+	}
+
+	// The view consults the collector's gauge aggregations, so create it before the provider
+	m := newMetrics(nil, config)
+
+	// Keep a reader of our own for Snapshot, which aggregates separately from the exporters
+	if config.EnableSnapshot {
+		m.snapshot = sdkmetric.NewManualReader()
+		readers = append(readers, m.snapshot)
 	}
 
 	// Create meter provider
-	meterProvider := sdkmetric.NewMeterProvider(
+	options := []sdkmetric.Option{
 		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
-	)
-	otel.SetMeterProvider(meterProvider)
+		sdkmetric.WithView(newMetricsView(config, m.gaugeAggregation)),
+	}
+	for _, reader := range readers {
+		options = append(options, sdkmetric.WithReader(reader))
+	}
+	meterProvider := sdkmetric.NewMeterProvider(options...)
+	if !config.DisableGlobalProvider {
+		otel.SetMeterProvider(meterProvider)
+	}
 
 	// Create meter
-	meter := meterProvider.Meter(config.ServiceName)
+	m.meter = meterProvider.Meter(config.ServiceName, metric.WithInstrumentationVersion(config.ServiceVersion))
+	m.enabled = true
+	m.flush = meterProvider.ForceFlush
+	m.shutdown = func() error {
+		return meterProvider.Shutdown(ctx)
+	}
 
+	return m, nil
+}
+
+// newMetrics creates a metrics collector recording to the given meter
+func newMetrics(meter metric.Meter, config MetricsConfig) *Metrics {
 	return &Metrics{
-		meter:      meter,
-		counters:   make(map[string]metric.Int64Counter),
-		gauges:     make(map[string]metric.Float64ObservableGauge),
-		histograms: make(map[string]metric.Float64Histogram),
-		shutdown: func() error {
-			return meterProvider.Shutdown(ctx)
-		},
-	}, nil
+		meter:          meter,
+		counters:       make(map[string]metric.Int64Counter),
+		upDownCounters: make(map[string]metric.Int64UpDownCounter),
+		gauges:         make(map[string]metric.Float64ObservableGauge),
+		histograms:     make(map[string]metric.Float64Histogram),
+		detachContext:  config.DetachContext,
+		clock:          wallClock{},
+		sanitizer:      config.Sanitizer,
+		flush:          func(context.Context) error { return nil },
+		shutdown:       func() error { return nil },
+
+		// Scoped metrics share the aggregations since they share the view
+		gaugeAggregations: &sync.Map{},
+		prefix:            config.NamePrefix,
+		validateUnits:     config.ValidateUnits,
+		instruments:       newInstrumentSet(config.MaxInstruments),
+	}
+}
+
+// scoped returns metrics sharing m's meter and exporters whose instrument names carry
+// the prefix and whose measurements carry attrs, both in addition to m's own.
+// Shutting down the scoped metrics does nothing; the parent owns the pipeline.
+func (m *Metrics) scoped(prefix string, attrs ...attribute.KeyValue) *Metrics {
+	scopedAttrs := make([]attribute.KeyValue, 0, len(m.attrs)+len(attrs))
+	scopedAttrs = append(scopedAttrs, m.attrs...)
+	scopedAttrs = append(scopedAttrs, attrs...)
+
+	return &Metrics{
+		meter:             m.meter,
+		enabled:           m.enabled,
+		counters:          make(map[string]metric.Int64Counter),
+		upDownCounters:    make(map[string]metric.Int64UpDownCounter),
+		gauges:            make(map[string]metric.Float64ObservableGauge),
+		histograms:        make(map[string]metric.Float64Histogram),
+		gaugeAggregations: m.gaugeAggregations,
+		detachContext:     m.detachContext,
+		clock:             m.clock,
+		sanitizer:         m.sanitizer,
+		flush:             m.flush,
+		shutdown:          func() error { return nil },
+		prefix:            m.prefix + prefix,
+		attrs:             scopedAttrs,
+		validateUnits:     m.validateUnits,
+		instruments:       m.instruments,
+		snapshot:          m.snapshot,
+	}
+}
+
+// Scoped returns metrics sharing m's exporters whose instrument names carry the prefix
+// and whose measurements carry attrs, both in addition to m's own. Shutting down the
+// scoped metrics does nothing; m owns the pipeline.
+func (m *Metrics) Scoped(prefix string, attrs ...attribute.KeyValue) MetricsInterface {
+	return m.scoped(prefix, attrs...)
+}
+
+// attributes returns the sanitized attributes of a measurement, including the scope's attributes
+func (m *Metrics) attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(m.attrs) > 0 {
+		attrs = append(append([]attribute.KeyValue{}, m.attrs...), attrs...)
+	}
+	return sanitizeAttributes(m.sanitizer, attrs)
+}
+
+// metricAttributesKey is the context key for metric attributes bound to a context
+type metricAttributesKey struct{}
+
+// ContextWithMetricAttributes returns a copy of ctx carrying attributes that are added to
+// every counter increment and histogram recording made with it. Attributes passed to the
+// call take precedence over bound ones with the same key.
+func ContextWithMetricAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	existing, _ := ctx.Value(metricAttributesKey{}).([]attribute.KeyValue)
+	merged := make([]attribute.KeyValue, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, metricAttributesKey{}, merged)
+}
+
+// contextAttributes prepends the attributes bound to ctx to attrs
+func contextAttributes(ctx context.Context, attrs []attribute.KeyValue) []attribute.KeyValue {
+	bound, _ := ctx.Value(metricAttributesKey{}).([]attribute.KeyValue)
+	if len(bound) == 0 {
+		return attrs
+	}
+	return append(append([]attribute.KeyValue{}, bound...), attrs...)
+}
+
+// newNoopMetrics creates a metrics collector that accepts measurements but exports nothing
+func newNoopMetrics(config MetricsConfig) *Metrics {
+	return newMetrics(noop.NewMeterProvider().Meter(config.ServiceName), config)
+}
+
+// newMetricReader creates a periodic reader for the exporter, applying the configured
+// export filters and, if spoolDir is set, spooling failed exports there
+func newMetricReader(config MetricsConfig, exporter sdkmetric.Exporter, spoolDir string) (sdkmetric.Reader, error) {
+	if config.CircuitBreaker != nil {
+		exporter = &breakerMetricExporter{Exporter: exporter, breaker: newCircuitBreaker(*config.CircuitBreaker)}
+	}
+	// Spool outside the breaker so collections it skips are kept too
+	if spoolDir != "" {
+		spool, err := NewFileSpoolMetricExporter(exporter, spoolDir)
+		if err != nil {
+			return nil, err
+		}
+		exporter = spool
+	}
+	if config.InstrumentTTL > 0 {
+		exporter = newStalenessExporter(exporter, config.InstrumentTTL)
+	}
+	return sdkmetric.NewPeriodicReader(exporter), nil
+}
+
+// metricExporterOptions builds the OTLP exporter options for the metrics configuration
+func metricExporterOptions(config MetricsConfig) []otlpmetricgrpc.Option {
+	options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithInsecure()}
+
+	// A URL endpoint lets its scheme decide whether the connection is secure
+	endpoint := resolveEndpoint(config.Endpoint, envOTLPMetricsEndpoint)
+	if strings.Contains(endpoint, "://") {
+		options = append(options, otlpmetricgrpc.WithEndpointURL(endpoint))
+	} else {
+		options = append(options, otlpmetricgrpc.WithEndpoint(endpoint))
+	}
+
+	if config.Retry != nil {
+		retry := config.Retry.withDefaults()
+		options = append(options, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+			Enabled:         retry.Enabled,
+			InitialInterval: retry.InitialInterval,
+			MaxInterval:     retry.MaxInterval,
+			MaxElapsedTime:  retry.MaxElapsedTime,
+		}))
+	}
+
+	if config.Compression == CompressionGzip {
+		options = append(options, otlpmetricgrpc.WithCompressor(CompressionGzip))
+	}
+
+	return options
+}
+
+// newMetricsView builds a view applying the configured stream customizations to every instrument.
+// gaugeAggregation returns the aggregation selected for an observable gauge, or nil for the default.
+func newMetricsView(config MetricsConfig, gaugeAggregation func(name string) sdkmetric.Aggregation) sdkmetric.View {
+	var attributeFilter attribute.Filter
+	if len(config.DropAttributes) > 0 {
+		keys := make([]attribute.Key, len(config.DropAttributes))
+		for i, key := range config.DropAttributes {
+			keys[i] = attribute.Key(key)
+		}
+		attributeFilter = attribute.NewDenyKeysFilter(keys...)
+	}
+	if sanitizer := config.Sanitizer; sanitizer != nil {
+		// The recording helpers rewrite attributes before aggregating them, but a view can
+		// only drop them, so instruments used directly lose attributes the sanitizer changes
+		deny := attributeFilter
+		attributeFilter = func(kv attribute.KeyValue) bool {
+			if deny != nil && !deny(kv) {
+				return false
+			}
+			sanitized, ok := sanitizer.Sanitize(kv)
+			return ok && sanitized == kv
+		}
+	}
+
+	exponential := make(map[string]bool, len(config.ExponentialHistograms))
+	for _, name := range config.ExponentialHistograms {
+		// Histograms are listed by the names they are recorded with
+		exponential[config.NamePrefix+name] = true
+	}
+	exponentialAggregation := sdkmetric.AggregationBase2ExponentialHistogram{
+		MaxSize:  defaultExponentialMaxSize,
+		MaxScale: defaultExponentialMaxScale,
+	}
+	if config.ExponentialMaxSize > 0 {
+		exponentialAggregation.MaxSize = config.ExponentialMaxSize
+	}
+	if config.ExponentialMaxScale > 0 {
+		exponentialAggregation.MaxScale = config.ExponentialMaxScale
+	}
+
+	return func(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		stream := sdkmetric.Stream{
+			Name:            inst.Name,
+			Description:     inst.Description,
+			Unit:            inst.Unit,
+			AttributeFilter: attributeFilter,
+		}
+		if inst.Kind == sdkmetric.InstrumentKindHistogram && exponential[inst.Name] {
+			stream.Aggregation = exponentialAggregation
+		}
+		if inst.Kind == sdkmetric.InstrumentKindObservableGauge {
+			if aggregation := gaugeAggregation(inst.Name); aggregation != nil {
+				stream.Aggregation = aggregation
+			}
+		}
+		return stream, true
+	}
 }
 
 // Shutdown stops the metrics collection
@@ -81,17 +413,83 @@ func (m *Metrics) Shutdown(ctx context.Context) error {
 	return m.shutdown()
 }
 
+// SetClock replaces the clock used to measure durations, e.g. with a fake in tests.
+// It must be called before any measurement is started.
+func (m *Metrics) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// ForceFlush exports all measurements recorded so far, returning any export error
+func (m *Metrics) ForceFlush(ctx context.Context) error {
+	return m.flush(ctx)
+}
+
+// RegisteredInstruments returns the sorted names, as exported with any prefix, of all
+// instruments created so far. A name used by instruments of several kinds is listed once.
+func (m *Metrics) RegisteredInstruments() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]struct{}, len(m.counters)+len(m.upDownCounters)+len(m.gauges)+len(m.histograms))
+	for name := range m.counters {
+		seen[m.prefix+name] = struct{}{}
+	}
+	for name := range m.upDownCounters {
+		seen[m.prefix+name] = struct{}{}
+	}
+	for name := range m.gauges {
+		seen[m.prefix+name] = struct{}{}
+	}
+	for name := range m.histograms {
+		seen[m.prefix+name] = struct{}{}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recordContext returns the context measurements are recorded with, detached from
+// cancellation when configured so values are not dropped during shutdown
+func (m *Metrics) recordContext(ctx context.Context) context.Context {
+	if m.detachContext {
+		return context.WithoutCancel(ctx)
+	}
+	return ctx
+}
+
 // CreateCounter creates a new counter metric
 func (m *Metrics) CreateCounter(name, description string) (metric.Int64Counter, error) {
+	return m.createCounter(name, description, "")
+}
+
+// createCounter creates a new counter metric with an optional unit
+func (m *Metrics) createCounter(name, description, unit string) (metric.Int64Counter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if counter, exists := m.counters[name]; exists {
 		return counter, nil
 	}
 
+	if err := m.checkUnit(name, unit); err != nil {
+		return nil, err
+	}
+	reserved, err := m.reserveInstrument(name)
+	if err != nil {
+		return nil, err
+	}
+
 	counter, err := m.meter.Int64Counter(
-		name,
+		m.prefix+name,
 		metric.WithDescription(description),
+		metric.WithUnit(unit),
 	)
 	if err != nil {
+		m.releaseInstrument(name, reserved)
 		return nil, fmt.Errorf("failed to create counter: %w", err)
 	}
 
@@ -101,7 +499,13 @@ func (m *Metrics) CreateCounter(name, description string) (metric.Int64Counter,
 
 // IncrementCounter increments a counter by the given value with optional attributes
 func (m *Metrics) IncrementCounter(ctx context.Context, name string, value int64, attrs ...attribute.KeyValue) error {
+	if value < 0 {
+		return fmt.Errorf("counter %s: %w: %d", name, ErrNegativeIncrement, value)
+	}
+
+	m.mu.RLock()
 	counter, exists := m.counters[name]
+	m.mu.RUnlock()
 	if !exists {
 		// If counter doesn't exist, create it
 		var err error
@@ -113,22 +517,90 @@ func (m *Metrics) IncrementCounter(ctx context.Context, name string, value int64
 		}
 	}
 
-	counter.Add(ctx, value, metric.WithAttributes(attrs...))
+	counter.Add(m.recordContext(ctx), value, metric.WithAttributes(m.attributes(contextAttributes(ctx, attrs))...))
 	return nil
 }
 
+// RecordAndFlush increments a counter and synchronously exports it before returning,
+// for critical paths that must not acknowledge work until its metrics are durable
+func (m *Metrics) RecordAndFlush(ctx context.Context, name string, value int64, attrs ...attribute.KeyValue) error {
+	if err := m.IncrementCounter(ctx, name, value, attrs...); err != nil {
+		return err
+	}
+	if err := m.ForceFlush(ctx); err != nil {
+		return fmt.Errorf("failed to flush metrics: %w", err)
+	}
+	return nil
+}
+
+// CreateUpDownCounter creates a new up/down counter metric
+func (m *Metrics) CreateUpDownCounter(name, description string) (metric.Int64UpDownCounter, error) {
+	return m.createUpDownCounter(name, description, "")
+}
+
+// createUpDownCounter creates a new up/down counter metric with an optional unit
+func (m *Metrics) createUpDownCounter(name, description, unit string) (metric.Int64UpDownCounter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if counter, exists := m.upDownCounters[name]; exists {
+		return counter, nil
+	}
+
+	if err := m.checkUnit(name, unit); err != nil {
+		return nil, err
+	}
+	reserved, err := m.reserveInstrument(name)
+	if err != nil {
+		return nil, err
+	}
+
+	counter, err := m.meter.Int64UpDownCounter(
+		m.prefix+name,
+		metric.WithDescription(description),
+		metric.WithUnit(unit),
+	)
+	if err != nil {
+		m.releaseInstrument(name, reserved)
+		return nil, fmt.Errorf("failed to create up/down counter: %w", err)
+	}
+
+	m.upDownCounters[name] = counter
+	return counter, nil
+}
+
 // CreateHistogram creates a new histogram metric
 func (m *Metrics) CreateHistogram(name, description, unit string) (metric.Float64Histogram, error) {
+	return m.createHistogram(name, description, unit, nil)
+}
+
+// createHistogram creates a new histogram metric with explicit bucket boundaries, or the SDK defaults if buckets is empty
+func (m *Metrics) createHistogram(name, description, unit string, buckets []float64) (metric.Float64Histogram, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if histogram, exists := m.histograms[name]; exists {
 		return histogram, nil
 	}
 
-	histogram, err := m.meter.Float64Histogram(
-		name,
+	if err := m.checkUnit(name, unit); err != nil {
+		return nil, err
+	}
+	reserved, err := m.reserveInstrument(name)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []metric.Float64HistogramOption{
 		metric.WithDescription(description),
 		metric.WithUnit(unit),
-	)
+	}
+	if len(buckets) > 0 {
+		options = append(options, metric.WithExplicitBucketBoundaries(buckets...))
+	}
+	histogram, err := m.meter.Float64Histogram(m.prefix+name, options...)
 	if err != nil {
+		m.releaseInstrument(name, reserved)
 		return nil, fmt.Errorf("failed to create histogram: %w", err)
 	}
 
@@ -138,7 +610,13 @@ func (m *Metrics) CreateHistogram(name, description, unit string) (metric.Float6
 
 // RecordHistogram records a value to a histogram with optional attributes
 func (m *Metrics) RecordHistogram(ctx context.Context, name string, value float64, attrs ...attribute.KeyValue) error {
+	if !isFinite(value) {
+		return fmt.Errorf("histogram %s: %w: %v", name, ErrNonFiniteValue, value)
+	}
+
+	m.mu.RLock()
 	histogram, exists := m.histograms[name]
+	m.mu.RUnlock()
 	if !exists {
 		// If histogram doesn't exist, create it
 		var err error
@@ -150,45 +628,256 @@ func (m *Metrics) RecordHistogram(ctx context.Context, name string, value float6
 		}
 	}
 
-	histogram.Record(ctx, value, metric.WithAttributes(attrs...))
+	histogram.Record(m.recordContext(ctx), value, metric.WithAttributes(m.attributes(contextAttributes(ctx, attrs))...))
 	return nil
 }
 
+// ErrNegativeIncrement is returned when a counter is incremented by a negative value;
+// values that go down belong in an up/down counter
+var ErrNegativeIncrement = errors.New("counters only increase, use an up/down counter for values that decrease")
+
+// ErrTooManyInstruments is returned when creating an instrument would exceed MaxInstruments
+var ErrTooManyInstruments = errors.New("instrument limit reached")
+
+// instrumentSet records the full names of the instruments counted against the cap.
+// Scoped metrics start with empty instrument maps, so the set is what keeps a name
+// created again through a new scope from counting twice.
+type instrumentSet struct {
+	max int
+
+	mu    sync.Mutex
+	names map[string]struct{}
+}
+
+// newInstrumentSet creates a set capped at max names, or returns nil if max is not positive
+func newInstrumentSet(max int) *instrumentSet {
+	if max <= 0 {
+		return nil
+	}
+	return &instrumentSet{max: max, names: make(map[string]struct{})}
+}
+
+// reserveInstrument counts the named instrument against the cap unless its full name
+// is already counted, reporting whether it added it. It returns ErrTooManyInstruments,
+// also warning through the otel error handler, if a new name would exceed the cap.
+func (m *Metrics) reserveInstrument(name string) (bool, error) {
+	if m.instruments == nil {
+		return false, nil
+	}
+	fullName := m.prefix + name
+
+	m.instruments.mu.Lock()
+	defer m.instruments.mu.Unlock()
+
+	if _, ok := m.instruments.names[fullName]; ok {
+		return false, nil
+	}
+	if len(m.instruments.names) >= m.instruments.max {
+		err := fmt.Errorf("instrument %s: %w: %d", fullName, ErrTooManyInstruments, m.instruments.max)
+		otel.Handle(err)
+		return false, err
+	}
+	m.instruments.names[fullName] = struct{}{}
+	return true, nil
+}
+
+// releaseInstrument uncounts an instrument that could not be created, if reserveInstrument added it
+func (m *Metrics) releaseInstrument(name string, reserved bool) {
+	if !reserved {
+		return
+	}
+	m.instruments.mu.Lock()
+	defer m.instruments.mu.Unlock()
+	delete(m.instruments.names, m.prefix+name)
+}
+
+// ErrUnknownUnit is returned when ValidateUnits is set and an instrument's unit is not
+// a recognized UCUM code
+var ErrUnknownUnit = errors.New("unit is not a recognized UCUM code")
+
+// ucumUnits are the UCUM codes accepted by unit validation
+var ucumUnits = map[string]bool{
+	"1": true, "%": true,
+	"ns": true, "us": true, "ms": true, "s": true, "min": true, "h": true, "d": true,
+	"bit": true, "By": true, "kBy": true, "MBy": true, "GBy": true, "KiBy": true, "MiBy": true, "GiBy": true,
+	"Hz": true, "m": true, "Cel": true, "W": true, "J": true,
+}
+
+// checkUnit returns ErrUnknownUnit if validation is enabled and unit is not a UCUM code.
+// Empty units and annotations in braces such as "{request}" are accepted, and units
+// may be divided as in "By/s".
+func (m *Metrics) checkUnit(name, unit string) error {
+	if !m.validateUnits || unit == "" {
+		return nil
+	}
+	for _, part := range strings.Split(unit, "/") {
+		if !ucumUnits[part] && !isUnitAnnotation(part) {
+			return fmt.Errorf("instrument %s: %w: %q", name, ErrUnknownUnit, unit)
+		}
+	}
+	return nil
+}
+
+// isUnitAnnotation reports whether part is a UCUM annotation such as "{request}"
+func isUnitAnnotation(part string) bool {
+	return len(part) > 2 && strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+}
+
+// ErrNonFiniteValue is returned when a NaN or infinite value is recorded
+var ErrNonFiniteValue = errors.New("value is NaN or infinite")
+
+// isFinite reports whether v is neither NaN nor infinite
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// observedAtKey is the attribute carrying the intended time of a backfilled observation
+const observedAtKey = "observed_at"
+
+// RecordHistogramAt records a value observed at a past time, e.g. when backfilling from a
+// batch job. The SDK always timestamps synchronous measurements with the collection time,
+// so the observation is exported now and the intended time is attached as an observed_at
+// attribute in RFC 3339 format. Each distinct time creates a new series, so only use it
+// with coarse timestamps.
+func (m *Metrics) RecordHistogramAt(ctx context.Context, name string, value float64, t time.Time, attrs ...attribute.KeyValue) error {
+	attrs = append(attrs[:len(attrs):len(attrs)], attribute.String(observedAtKey, t.UTC().Format(time.RFC3339)))
+	return m.RecordHistogram(ctx, name, value, attrs...)
+}
+
 // CreateGauge creates a new gauge metric
 func (m *Metrics) CreateGauge(name, description string, callback func() float64) (metric.Float64ObservableGauge, error) {
+	return m.createGauge(name, description, callback)
+}
+
+// createGauge creates a new gauge metric whose observations carry the given attributes
+func (m *Metrics) createGauge(name, description string, callback func() float64, attrs ...attribute.KeyValue) (metric.Float64ObservableGauge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if gauge, exists := m.gauges[name]; exists {
 		return gauge, nil
 	}
+	reserved, err := m.reserveInstrument(name)
+	if err != nil {
+		return nil, err
+	}
 
 	gauge, err := m.meter.Float64ObservableGauge(
-		name,
+		m.prefix+name,
 		metric.WithDescription(description),
 	)
 	if err != nil {
+		m.releaseInstrument(name, reserved)
 		return nil, fmt.Errorf("failed to create gauge: %w", err)
 	}
 
-	_, err = m.meter.RegisterCallback(
+	if err := m.observeGauge(gauge, name, callback, attrs...); err != nil {
+		m.releaseInstrument(name, reserved)
+		return nil, err
+	}
+
+	m.gauges[name] = gauge
+	return gauge, nil
+}
+
+// addGaugeObserver registers another callback reporting a series of the named gauge,
+// creating the gauge if needed, so several sources can report it with distinct attributes
+func (m *Metrics) addGaugeObserver(name, description string, callback func() float64, attrs ...attribute.KeyValue) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gauge, exists := m.gauges[name]
+	reserved := false
+	if !exists {
+		var err error
+		if reserved, err = m.reserveInstrument(name); err != nil {
+			return err
+		}
+		gauge, err = m.meter.Float64ObservableGauge(
+			m.prefix+name,
+			metric.WithDescription(description),
+		)
+		if err != nil {
+			m.releaseInstrument(name, reserved)
+			return fmt.Errorf("failed to create gauge: %w", err)
+		}
+	}
+
+	if err := m.observeGauge(gauge, name, callback, attrs...); err != nil {
+		m.releaseInstrument(name, reserved)
+		return err
+	}
+
+	m.gauges[name] = gauge
+	return nil
+}
+
+// observeGauge registers a callback observing the gauge with the given attributes
+func (m *Metrics) observeGauge(gauge metric.Float64ObservableGauge, name string, callback func() float64, attrs ...attribute.KeyValue) error {
+	_, err := m.meter.RegisterCallback(
 		func(_ context.Context, observer metric.Observer) error {
-			observer.ObserveFloat64(gauge, callback())
+			value := callback()
+			if !isFinite(value) {
+				// Skip the observation so one bad value doesn't corrupt the series
+				otel.Handle(fmt.Errorf("gauge %s: %w: %v", name, ErrNonFiniteValue, value))
+				return nil
+			}
+			observer.ObserveFloat64(gauge, value, metric.WithAttributes(m.attributes(attrs)...))
 			return nil
 		},
 		gauge,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to register callback: %w", err)
+		return fmt.Errorf("failed to register callback: %w", err)
 	}
+	return nil
+}
 
-	m.gauges[name] = gauge
-	return gauge, nil
+// CreateGaugeWithAggregation creates a new gauge metric aggregated with the given aggregation
+// instead of the default last value, e.g. sdkmetric.AggregationExplicitBucketHistogram to keep
+// the min and max observed over each interval. The aggregation only applies if the gauge does
+// not exist yet and metrics are exported.
+func (m *Metrics) CreateGaugeWithAggregation(name, description string, aggregation sdkmetric.Aggregation, callback func() float64) (metric.Float64ObservableGauge, error) {
+	m.gaugeAggregations.LoadOrStore(m.prefix+name, aggregation)
+	return m.CreateGauge(name, description, callback)
+}
+
+// gaugeAggregation returns the aggregation selected for a gauge, or nil for the default
+func (m *Metrics) gaugeAggregation(name string) sdkmetric.Aggregation {
+	if aggregation, ok := m.gaugeAggregations.Load(name); ok {
+		return aggregation.(sdkmetric.Aggregation)
+	}
+	return nil
+}
+
+// buildInfoMetric reports 1 tagged with the build of the running binary
+const buildInfoMetric = "build_info"
+
+// RegisterBuildInfo registers the build_info gauge, which always reports 1 tagged with
+// the version, commit and build date, to correlate metrics with deploys.
+// It does nothing when metrics are disabled.
+func (m *Metrics) RegisterBuildInfo(version, commit, date string) error {
+	if !m.enabled {
+		return nil
+	}
+	_, err := m.createGauge(buildInfoMetric, "Build information of the running binary",
+		func() float64 { return 1 },
+		attribute.String("version", version),
+		attribute.String("commit", commit),
+		attribute.String("build_date", date),
+	)
+	return err
 }
 
-// MeasureDuration measures the duration of a function call and records it to a histogram
+// MeasureDuration measures the duration of a function call and records it to a histogram.
+// If ctx is done when the returned func runs, a canceled or deadline_exceeded attribute is added.
 func (m *Metrics) MeasureDuration(ctx context.Context, name string, attrs ...attribute.KeyValue) func() {
-	start := time.Now()
+	start := m.clock.Now()
 	return func() {
-		duration := time.Since(start).Seconds()
+		duration := m.clock.Now().Sub(start).Seconds()
+		m.mu.RLock()
 		histogram, exists := m.histograms[name]
+		m.mu.RUnlock()
 		if !exists {
 			// If histogram doesn't exist, create it
 			var err error
@@ -199,6 +888,58 @@ func (m *Metrics) MeasureDuration(ctx context.Context, name string, attrs ...att
 				return
 			}
 		}
-		histogram.Record(ctx, duration, metric.WithAttributes(attrs...))
+		// Copy so the flags aren't appended into the caller's backing array
+		attrs := append(append([]attribute.KeyValue{}, contextAttributes(ctx, attrs)...), contextErrorAttributes(ctx)...)
+		histogram.Record(m.recordContext(ctx), duration, metric.WithAttributes(m.attributes(attrs)...))
+	}
+}
+
+// contextErrorAttributes flags measurements of operations aborted by their context
+func contextErrorAttributes(ctx context.Context) []attribute.KeyValue {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return []attribute.KeyValue{attribute.Bool("deadline_exceeded", true)}
+	case errors.Is(ctx.Err(), context.Canceled):
+		return []attribute.KeyValue{attribute.Bool("canceled", true)}
+	default:
+		return nil
 	}
 }
+
+// Timer measures the duration of an operation and records it to a histogram when stopped
+type Timer struct {
+	metrics *Metrics
+	ctx     context.Context
+	name    string
+	start   time.Time
+	attrs   []attribute.KeyValue
+}
+
+// StartTimer starts a timer that records to the named histogram with optional attributes
+func (m *Metrics) StartTimer(ctx context.Context, name string, attrs ...attribute.KeyValue) *Timer {
+	return &Timer{
+		metrics: m,
+		ctx:     ctx,
+		name:    name,
+		start:   m.clock.Now(),
+		attrs:   attrs,
+	}
+}
+
+// Stop records the elapsed time and returns it
+func (t *Timer) Stop() time.Duration {
+	return t.StopWith()
+}
+
+// StopWith records the elapsed time with additional attributes known only at the end and returns it
+func (t *Timer) StopWith(attrs ...attribute.KeyValue) time.Duration {
+	elapsed := t.metrics.clock.Now().Sub(t.start)
+
+	all := make([]attribute.KeyValue, 0, len(t.attrs)+len(attrs))
+	all = append(all, t.attrs...)
+	all = append(all, attrs...)
+
+	// RecordHistogram already reports creation failures
+	_ = t.metrics.RecordHistogram(t.ctx, t.name, elapsed.Seconds(), all...)
+	return elapsed
+}
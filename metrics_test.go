@@ -0,0 +1,593 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newTestMetrics creates enabled metrics backed by a manual reader the test can collect from
+func newTestMetrics(t *testing.T, config MetricsConfig) (*Metrics, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	m := newMetrics(nil, config)
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(newMetricsView(config, m.gaugeAggregation)),
+	)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	m.meter = provider.Meter("test")
+	m.enabled = true
+	m.flush = provider.ForceFlush
+	return m, reader
+}
+
+// collect returns the metrics collected by the reader, keyed by name
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	metrics := make(map[string]metricdata.Metrics)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func TestDropAttributes(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{DropAttributes: []string{"url.full"}})
+	ctx := context.Background()
+
+	for _, url := range []string{"https://example.com/a?id=1", "https://example.com/a?id=2"} {
+		attrs := []attribute.KeyValue{attribute.String("url.full", url), attribute.String("http.method", "GET")}
+		if err := m.IncrementCounter(ctx, "requests", 1, attrs...); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.RecordHistogram(ctx, "latency", 0.1, attrs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics := collect(t, reader)
+	counter := metrics["requests"].Data.(metricdata.Sum[int64])
+	if len(counter.DataPoints) != 1 || counter.DataPoints[0].Value != 2 {
+		t.Fatalf("counter points = %+v, want one series with both increments", counter.DataPoints)
+	}
+	histogram := metrics["latency"].Data.(metricdata.Histogram[float64])
+	if len(histogram.DataPoints) != 1 {
+		t.Fatalf("histogram points = %+v, want one series", histogram.DataPoints)
+	}
+	for _, attrs := range []attribute.Set{counter.DataPoints[0].Attributes, histogram.DataPoints[0].Attributes} {
+		if attrs.HasValue("url.full") {
+			t.Errorf("attributes %v, want url.full dropped", attrs.ToSlice())
+		}
+		if !attrs.HasValue("http.method") {
+			t.Errorf("attributes %v, want http.method kept", attrs.ToSlice())
+		}
+	}
+}
+
+func TestExponentialHistograms(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{
+		ExponentialHistograms: []string{"latency"},
+		ExponentialMaxScale:   10,
+		ExponentialMaxSize:    80,
+	})
+	ctx := context.Background()
+
+	for _, v := range []float64{0.001, 0.5, 30} {
+		if err := m.RecordHistogram(ctx, "latency", v); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.RecordHistogram(ctx, "payload", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics := collect(t, reader)
+	latency, ok := metrics["latency"].Data.(metricdata.ExponentialHistogram[float64])
+	if !ok {
+		t.Fatalf("latency recorded as %T, want an exponential histogram", metrics["latency"].Data)
+	}
+	if dp := latency.DataPoints[0]; dp.Count != 3 || dp.Scale > 10 {
+		t.Errorf("latency count = %d, scale = %d, want 3 values at scale <= 10", dp.Count, dp.Scale)
+	}
+	if _, ok := metrics["payload"].Data.(metricdata.Histogram[float64]); !ok {
+		t.Errorf("payload recorded as %T, want explicit buckets", metrics["payload"].Data)
+	}
+}
+
+func TestTimerStopWith(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m.SetClock(clock)
+
+	timer := m.StartTimer(context.Background(), "job_duration", attribute.String("queue", "emails"))
+	clock.advance(1500 * time.Millisecond)
+	if elapsed := timer.StopWith(attribute.String("outcome", "retried")); elapsed != 1500*time.Millisecond {
+		t.Errorf("StopWith() = %v, want 1.5s", elapsed)
+	}
+
+	histogram := collect(t, reader)["job_duration"].Data.(metricdata.Histogram[float64])
+	if len(histogram.DataPoints) != 1 {
+		t.Fatalf("got %d points, want 1", len(histogram.DataPoints))
+	}
+	point := histogram.DataPoints[0]
+	if point.Count != 1 || point.Sum != 1.5 {
+		t.Errorf("recorded count %d sum %g, want one 1.5s duration", point.Count, point.Sum)
+	}
+	for key, want := range map[attribute.Key]string{"queue": "emails", "outcome": "retried"} {
+		if v, _ := point.Attributes.Value(key); v.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, v.AsString(), want)
+		}
+	}
+}
+
+func TestDetachContextRecordsAfterCancel(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{DetachContext: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := m.IncrementCounter(ctx, "shutdown_jobs", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	counter := collect(t, reader)["shutdown_jobs"].Data.(metricdata.Sum[int64])
+	if len(counter.DataPoints) != 1 || counter.DataPoints[0].Value != 3 {
+		t.Errorf("counter points = %+v, want the value recorded despite the canceled context", counter.DataPoints)
+	}
+	if got := m.recordContext(ctx).Err(); got != nil {
+		t.Errorf("recording context error = %v, want it detached from cancellation", got)
+	}
+}
+
+func TestRegisteredInstruments(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{})
+	ctx := context.Background()
+
+	if _, err := m.CreateCounter("requests", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateUpDownCounter("in_flight", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateGauge("queue_depth", "", func() float64 { return 1 }); err != nil {
+		t.Fatal(err)
+	}
+	// Lazily created by recording
+	if err := m.RecordHistogram(ctx, "latency", 0.1); err != nil {
+		t.Fatal(err)
+	}
+	// A name shared by instruments of different kinds is listed once
+	if _, err := m.CreateHistogram("requests", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = m.IncrementCounter(ctx, "requests", 1)
+			_ = m.RegisteredInstruments()
+		}()
+	}
+	wg.Wait()
+
+	got := strings.Join(m.RegisteredInstruments(), ",")
+	if want := "in_flight,latency,queue_depth,requests"; got != want {
+		t.Errorf("RegisteredInstruments() = %s, want %s", got, want)
+	}
+}
+
+func TestRecordAndFlush(t *testing.T) {
+	ctx := context.Background()
+	exporter := &flakyMetricExporter{}
+	m, err := NewMetrics(ctx, MetricsConfig{
+		Enabled:               true,
+		DisableGlobalProvider: true,
+		MetricExporter:        exporter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = m.Shutdown(ctx) }()
+
+	if err := m.RecordAndFlush(ctx, "payments_settled", 5); err != nil {
+		t.Fatal(err)
+	}
+	if total, ok := exporter.counter("payments_settled"); !ok || total != 5 {
+		t.Errorf("exported total = %d (exported %v), want 5 before RecordAndFlush returned", total, ok)
+	}
+
+	exporter.mu.Lock()
+	exporter.down = true
+	exporter.mu.Unlock()
+	if err := m.RecordAndFlush(ctx, "payments_settled", 1); !errors.Is(err, errCollectorDown) {
+		t.Errorf("RecordAndFlush() with a failing exporter = %v, want the export error", err)
+	}
+}
+
+func TestCreateGaugeWithAggregation(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+
+	if _, err := m.CreateGauge("temperature", "", func() float64 { return 21.5 }); err != nil {
+		t.Fatal(err)
+	}
+	aggregation := sdkmetric.AggregationExplicitBucketHistogram{Boundaries: []float64{10, 100}, NoMinMax: false}
+	if _, err := m.CreateGaugeWithAggregation("queue_depth", "", aggregation, func() float64 { return 42 }); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := collect(t, reader)
+	if gauge, ok := metrics["temperature"].Data.(metricdata.Gauge[float64]); !ok || gauge.DataPoints[0].Value != 21.5 {
+		t.Errorf("temperature = %#v, want a last-value gauge", metrics["temperature"].Data)
+	}
+	histogram, ok := metrics["queue_depth"].Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("queue_depth recorded as %T, want the histogram aggregation", metrics["queue_depth"].Data)
+	}
+	if max, _ := histogram.DataPoints[0].Max.Value(); max != 42 {
+		t.Errorf("queue_depth max = %g, want 42", max)
+	}
+}
+
+func TestMeasureDurationFlagsDeadlineExceeded(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	// Spare capacity would let an append write the flag into the caller's array
+	attrs := make([]attribute.KeyValue, 1, 4)
+	attrs[0] = attribute.String("operation", "query")
+	sentinel := attribute.String("caller", "owned")
+	attrs[:2][1] = sentinel
+
+	m.MeasureDuration(ctx, "query_duration", attrs...)()
+
+	if got := attrs[:2][1]; got != sentinel {
+		t.Errorf("caller's backing array was overwritten with %v", got)
+	}
+
+	histogram, ok := collect(t, reader)["query_duration"].Data.(metricdata.Histogram[float64])
+	if !ok || len(histogram.DataPoints) != 1 {
+		t.Fatalf("got %+v, want one histogram point", histogram)
+	}
+	point := histogram.DataPoints[0]
+	if v, ok := point.Attributes.Value("deadline_exceeded"); !ok || !v.AsBool() {
+		t.Errorf("attributes %v, want deadline_exceeded=true", point.Attributes.ToSlice())
+	}
+	if v, ok := point.Attributes.Value("operation"); !ok || v.AsString() != "query" {
+		t.Errorf("attributes %v, want the caller's operation attribute", point.Attributes.ToSlice())
+	}
+}
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestMeasureDurationUsesClock(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m.SetClock(clock)
+
+	done := m.MeasureDuration(context.Background(), "render_duration")
+	clock.advance(250 * time.Millisecond)
+	done()
+
+	histogram := collect(t, reader)["render_duration"].Data.(metricdata.Histogram[float64])
+	if len(histogram.DataPoints) != 1 {
+		t.Fatalf("got %d points, want 1", len(histogram.DataPoints))
+	}
+	if point := histogram.DataPoints[0]; point.Count != 1 || point.Sum != 0.25 {
+		t.Errorf("recorded count %d sum %g, want one 0.25s duration", point.Count, point.Sum)
+	}
+}
+
+func TestRecordHistogramRejectsNonFiniteValues(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	ctx := context.Background()
+
+	for _, value := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		if err := m.RecordHistogram(ctx, "ratio", value); !errors.Is(err, ErrNonFiniteValue) {
+			t.Errorf("RecordHistogram(%v) = %v, want ErrNonFiniteValue", value, err)
+		}
+	}
+	if err := m.RecordHistogram(ctx, "ratio", 2.5); err != nil {
+		t.Fatal(err)
+	}
+
+	histogram := collect(t, reader)["ratio"].Data.(metricdata.Histogram[float64])
+	if point := histogram.DataPoints[0]; point.Count != 1 || point.Sum != 2.5 {
+		t.Errorf("recorded count %d sum %g, want only the finite value", point.Count, point.Sum)
+	}
+}
+
+func TestGaugeSkipsNonFiniteObservations(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+
+	var handled []error
+	original := otel.GetErrorHandler()
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { handled = append(handled, err) }))
+	t.Cleanup(func() { otel.SetErrorHandler(original) })
+
+	values := []float64{math.NaN(), 3}
+	if _, err := m.CreateGauge("queue_depth", "Jobs waiting", func() float64 {
+		value := values[0]
+		values = values[1:]
+		return value
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, ok := collect(t, reader)["queue_depth"]; ok && len(data.Data.(metricdata.Gauge[float64]).DataPoints) != 0 {
+		t.Errorf("NaN observation was exported: %+v", data.Data)
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], ErrNonFiniteValue) {
+		t.Errorf("handled errors = %v, want one ErrNonFiniteValue", handled)
+	}
+
+	gauge := collect(t, reader)["queue_depth"].Data.(metricdata.Gauge[float64])
+	if len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != 3 {
+		t.Errorf("gauge points = %+v, want the finite observation", gauge.DataPoints)
+	}
+}
+
+func TestIncrementCounterRejectsNegativeValues(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	ctx := context.Background()
+
+	err := m.IncrementCounter(ctx, "refunds", -1)
+	if !errors.Is(err, ErrNegativeIncrement) || !strings.Contains(err.Error(), "refunds") {
+		t.Errorf("IncrementCounter(-1) = %v, want ErrNegativeIncrement naming the counter", err)
+	}
+	if _, ok := collect(t, reader)["refunds"]; ok {
+		t.Error("negative increment was recorded")
+	}
+
+	for _, value := range []int64{0, 2} {
+		if err := m.IncrementCounter(ctx, "refunds", value); err != nil {
+			t.Errorf("IncrementCounter(%d) = %v, want nil", value, err)
+		}
+	}
+	sum := collect(t, reader)["refunds"].Data.(metricdata.Sum[int64])
+	if len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 2 {
+		t.Errorf("refunds = %+v, want 2", sum.DataPoints)
+	}
+}
+
+func TestNamePrefix(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{NamePrefix: "orders."})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := m.IncrementCounter(ctx, "created", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Looking the counter up again reuses the cached instrument
+	if _, err := m.CreateCounter("created", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordHistogram(ctx, "latency", 0.2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateGauge("pending", "", func() float64 { return 4 }); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := collect(t, reader)
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"orders.created", "orders.latency", "orders.pending"}; !slices.Equal(names, want) {
+		t.Fatalf("exported %v, want %v", names, want)
+	}
+	if got := m.RegisteredInstruments(); !slices.Equal(got, names) {
+		t.Errorf("RegisteredInstruments() = %v, want the exported names %v", got, names)
+	}
+	if sum := metrics["orders.created"].Data.(metricdata.Sum[int64]); sum.DataPoints[0].Value != 2 {
+		t.Errorf("orders.created = %d, want 2", sum.DataPoints[0].Value)
+	}
+}
+
+func TestRegisterBuildInfo(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	if err := m.RegisterBuildInfo("1.4.2", "9f3c2ab", "2024-05-01"); err != nil {
+		t.Fatal(err)
+	}
+
+	gauge, ok := collect(t, reader)[buildInfoMetric].Data.(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != 1 {
+		t.Fatalf("%s = %+v, want one point reporting 1", buildInfoMetric, gauge.DataPoints)
+	}
+	want := attribute.NewSet(
+		attribute.String("version", "1.4.2"),
+		attribute.String("commit", "9f3c2ab"),
+		attribute.String("build_date", "2024-05-01"),
+	)
+	if attrs := gauge.DataPoints[0].Attributes; !attrs.Equals(&want) {
+		t.Errorf("attributes = %v, want %v", attrs.ToSlice(), want.ToSlice())
+	}
+}
+
+func TestRegisterBuildInfoWithMetricsDisabled(t *testing.T) {
+	m, err := NewMetrics(context.Background(), MetricsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegisterBuildInfo("1.4.2", "9f3c2ab", "2024-05-01"); err != nil {
+		t.Fatal(err)
+	}
+	if names := m.RegisteredInstruments(); len(names) != 0 {
+		t.Errorf("registered %v, want nothing while disabled", names)
+	}
+}
+
+func TestRecordHistogramAt(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	observed := time.Date(2024, 3, 9, 14, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	attrs := make([]attribute.KeyValue, 1, 4)
+	attrs[0] = attribute.String("job", "nightly")
+	if err := m.RecordHistogramAt(context.Background(), "batch_size", 120, observed, attrs...); err != nil {
+		t.Fatal(err)
+	}
+	if got := attrs[:2][1]; got.Valid() {
+		t.Errorf("caller's backing array was overwritten with %v", got)
+	}
+
+	histogram := collect(t, reader)["batch_size"].Data.(metricdata.Histogram[float64])
+	if len(histogram.DataPoints) != 1 {
+		t.Fatalf("got %d points, want 1", len(histogram.DataPoints))
+	}
+	point := histogram.DataPoints[0]
+	if point.Count != 1 || point.Sum != 120 {
+		t.Errorf("recorded count %d sum %g, want the value", point.Count, point.Sum)
+	}
+	if v, _ := point.Attributes.Value(observedAtKey); v.AsString() != "2024-03-09T13:30:00Z" {
+		t.Errorf("%s = %q, want the observation time in UTC", observedAtKey, v.AsString())
+	}
+	if v, _ := point.Attributes.Value("job"); v.AsString() != "nightly" {
+		t.Errorf("job = %q, want the caller's attribute", v.AsString())
+	}
+}
+
+func TestContextWithMetricAttributes(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	ctx := ContextWithMetricAttributes(context.Background(), attribute.String("tenant", "acme"), attribute.String("region", "eu"))
+	ctx = ContextWithMetricAttributes(ctx, attribute.String("region", "us"))
+
+	if err := m.IncrementCounter(ctx, "requests", 1, attribute.String("route", "/orders")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordHistogram(ctx, "latency", 0.3, attribute.String("tenant", "globex")); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := collect(t, reader)
+	requests := metrics["requests"].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+	want := attribute.NewSet(attribute.String("tenant", "acme"), attribute.String("region", "us"), attribute.String("route", "/orders"))
+	if !requests.Equals(&want) {
+		t.Errorf("requests attributes = %v, want %v", requests.ToSlice(), want.ToSlice())
+	}
+	// Attributes passed to the call win over the bound ones
+	latency := metrics["latency"].Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+	want = attribute.NewSet(attribute.String("tenant", "globex"), attribute.String("region", "us"))
+	if !latency.Equals(&want) {
+		t.Errorf("latency attributes = %v, want %v", latency.ToSlice(), want.ToSlice())
+	}
+}
+
+func TestMeasureDurationUsesContextAttributes(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	ctx := ContextWithMetricAttributes(context.Background(), attribute.String("tenant", "acme"))
+
+	m.MeasureDuration(ctx, "db_query", attribute.String("table", "orders"))()
+
+	attrs := collect(t, reader)["db_query"].Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+	want := attribute.NewSet(attribute.String("tenant", "acme"), attribute.String("table", "orders"))
+	if !attrs.Equals(&want) {
+		t.Errorf("db_query attributes = %v, want %v", attrs.ToSlice(), want.ToSlice())
+	}
+}
+
+func TestValidateUnits(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{ValidateUnits: true})
+
+	tests := []struct {
+		unit  string
+		valid bool
+	}{
+		{"", true},
+		{"s", true},
+		{"ms", true},
+		{"By", true},
+		{"By/s", true},
+		{"{request}", true},
+		{"{request}/s", true},
+		{"seconds", false},
+		{"bytes/s", false},
+		{"{}", false},
+		{"ms/", false},
+	}
+	for _, tt := range tests {
+		err := m.checkUnit("latency", tt.unit)
+		if tt.valid && err != nil {
+			t.Errorf("checkUnit(%q) = %v, want nil", tt.unit, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrUnknownUnit) {
+			t.Errorf("checkUnit(%q) = %v, want ErrUnknownUnit", tt.unit, err)
+		}
+	}
+
+	if _, err := m.CreateHistogram("latency", "Request latency", "seconds"); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("CreateHistogram with unit %q = %v, want ErrUnknownUnit", "seconds", err)
+	}
+	if _, err := m.CreateHistogram("latency", "Request latency", "s"); err != nil {
+		t.Errorf("CreateHistogram after a rejected unit: %v", err)
+	}
+}
+
+func TestUnitsNotValidatedByDefault(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{})
+
+	if _, err := m.CreateHistogram("latency", "Request latency", "seconds"); err != nil {
+		t.Errorf("CreateHistogram without ValidateUnits: %v", err)
+	}
+}
+
+func TestMaxInstruments(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{MaxInstruments: 2})
+
+	if _, err := m.CreateCounter("a", ""); err != nil {
+		t.Fatalf("first instrument: %v", err)
+	}
+	if _, err := m.CreateHistogram("b", "", "s"); err != nil {
+		t.Fatalf("second instrument: %v", err)
+	}
+	if _, err := m.CreateCounter("a", ""); err != nil {
+		t.Errorf("existing instrument counted again: %v", err)
+	}
+	if _, err := m.CreateCounter("c", ""); !errors.Is(err, ErrTooManyInstruments) {
+		t.Errorf("instrument past the cap: got %v, want ErrTooManyInstruments", err)
+	}
+}
+
+func TestMaxInstrumentsCountsScopedNamesOnce(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{MaxInstruments: 3})
+	provider := &ObservabilityProvider{Metrics: m}
+	ctx := context.Background()
+
+	// Each ForTenant call scopes afresh, but the instrument is the same
+	for i := 0; i < 5; i++ {
+		if err := provider.ForTenant("a").Metrics.IncrementCounter(ctx, "requests", 1); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if err := provider.ForTenant("b").Metrics.IncrementCounter(ctx, "requests", 1); err != nil {
+		t.Fatalf("second tenant: %v", err)
+	}
+}
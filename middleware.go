@@ -0,0 +1,299 @@
+package observability
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header request IDs are read from and echoed back in
+const RequestIDHeader = "X-Request-Id"
+
+// TraceIDHeader is the header error responses carry the trace ID in
+const TraceIDHeader = "X-Trace-Id"
+
+// maxRequestIDLength bounds accepted incoming request IDs; longer ones are replaced
+const maxRequestIDLength = 128
+
+const (
+	httpServerRequestsMetric       = "http.server.requests"
+	httpServerActiveRequestsMetric = "http.server.active_requests"
+	httpServerDurationMetric       = "http.server.duration"
+)
+
+// statusRecorder wraps an http.ResponseWriter to capture the response status code
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// newStatusRecorder creates a statusRecorder defaulting to 200 OK
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader records the status code before delegating to the wrapped writer
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks the header as written before delegating to the wrapped writer
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer so http.ResponseController can reach it
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush flushes the wrapped writer if it supports flushing, so streaming handlers
+// such as server-sent events keep working behind the recorder
+func (r *statusRecorder) Flush() {
+	r.wroteHeader = true
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands over the wrapped writer's connection, e.g. for websocket upgrades
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %T cannot be hijacked", http.ErrNotSupported, r.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+// ReadFrom lets the wrapped writer copy from src directly, e.g. with sendfile
+func (r *statusRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.wroteHeader = true
+	if readerFrom, ok := r.ResponseWriter.(io.ReaderFrom); ok {
+		return readerFrom.ReadFrom(src)
+	}
+	return io.Copy(r.ResponseWriter, src)
+}
+
+// HTTPMiddleware records request count, in-flight requests and latency for every request
+func (m *Metrics) HTTPMiddleware(next http.Handler) http.Handler {
+	requests, err := m.CreateCounter(httpServerRequestsMetric, "Number of HTTP requests served")
+	if err != nil {
+		fmt.Printf("Failed to create counter: %v\n", err)
+		return next
+	}
+
+	active, err := m.CreateUpDownCounter(httpServerActiveRequestsMetric, "Number of in-flight HTTP requests")
+	if err != nil {
+		fmt.Printf("Failed to create up/down counter: %v\n", err)
+		return next
+	}
+
+	duration, err := m.CreateHistogram(httpServerDurationMetric, "Duration of HTTP requests", "s")
+	if err != nil {
+		fmt.Printf("Failed to create histogram: %v\n", err)
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := m.recordContext(r.Context())
+		method := attribute.String("http.method", r.Method)
+
+		activeAttrs := metric.WithAttributes(m.attributes([]attribute.KeyValue{method})...)
+		active.Add(ctx, 1, activeAttrs)
+		defer active.Add(ctx, -1, activeAttrs)
+
+		recorder := newStatusRecorder(w)
+		start := m.clock.Now()
+		panicked := true
+		// Record from a defer so requests whose handler panics are counted too
+		defer func() {
+			elapsed := m.clock.Now().Sub(start).Seconds()
+			status := recorder.status
+			if panicked && !recorder.wroteHeader {
+				status = http.StatusInternalServerError
+			}
+
+			// The route is only known once the mux has matched the request
+			attrs := metric.WithAttributes(m.attributes([]attribute.KeyValue{
+				method,
+				attribute.String("http.route", r.Pattern),
+				attribute.Int("http.status_code", status),
+			})...)
+			requests.Add(ctx, 1, attrs)
+			duration.Record(ctx, elapsed, attrs)
+		}()
+		next.ServeHTTP(recorder, r)
+		panicked = false
+	})
+}
+
+// requestIDKey is the context key for the request ID
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID in its value, baggage and log fields
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	ctx = ContextWithFields(ctx, RequestIDField(requestID))
+
+	// An invalid request ID is still usable locally, it just isn't propagated
+	if withBaggage, err := SetBaggage(ctx, string(RequestIDKey), requestID); err == nil {
+		ctx = withBaggage
+	}
+
+	return ctx
+}
+
+// RequestIDFromContext returns the request ID stored in the context, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestIDMiddleware ensures every request has a request ID, reading it from the
+// X-Request-Id header or generating one, and echoes it back in the response
+func (p *ObservabilityProvider) RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		ctx := ContextWithRequestID(r.Context(), requestID)
+		trace.SpanFromContext(ctx).SetAttributes(RequestID(requestID))
+
+		w.Header().Set(RequestIDHeader, requestID)
+		serveWithContext(next, w, r, ctx)
+	})
+}
+
+// HTTPMiddleware starts a server span for every request, continuing any trace propagated
+// in the request headers, and records the matched route and response status on it.
+// A panicking handler is recovered: the panic is recorded on the span and logged with
+// its stack to the logger bound to the request context, and the client gets a 500.
+func (t *Tracer) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		recorder := newStatusRecorder(w)
+		func() {
+			defer recoverHTTPPanic(ctx, span, recorder)
+			serveWithContext(next, recorder, r, ctx)
+		}()
+
+		// The route is only known once the mux has matched the request
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+		SetHTTPStatus(span, recorder.status)
+	})
+}
+
+// Middleware instruments every request in one wrapper: it records request metrics, binds a
+// request-scoped logger retrievable with LoggerFromContext, starts a server span that
+// recovers handler panics, and ensures a request ID that is echoed in the response
+func (p *ObservabilityProvider) Middleware(next http.Handler) http.Handler {
+	traced := p.RequestIDMiddleware(next)
+	if p.Tracer != nil {
+		traced = p.Tracer.HTTPMiddleware(traced)
+	}
+	// Bind the logger outside the span so panics recovered by the tracer are logged with it
+	bindLogger := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only a *Logger can be bound; LoggerFromContext returns the concrete type
+		logger, ok := p.Logger.(*Logger)
+		if !ok {
+			traced.ServeHTTP(w, r)
+			return
+		}
+		logger = logger.With(
+			zap.String("http.method", r.Method),
+			zap.String("http.target", r.URL.Path),
+		)
+		serveWithContext(traced, w, r, ContextWithLogger(r.Context(), logger))
+	})
+	if p.Metrics != nil {
+		return p.Metrics.HTTPMiddleware(bindLogger)
+	}
+	return bindLogger
+}
+
+// recoverHTTPPanic recovers a panicking handler, recording the panic on the span, logging
+// it with its stack and answering 500 if nothing was written yet. http.ErrAbortHandler
+// is re-raised since net/http uses it to abort a response on purpose.
+func recoverHTTPPanic(ctx context.Context, span trace.Span, w *statusRecorder) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+
+	stack := string(debug.Stack())
+	err := fmt.Errorf("panic: %v", v)
+	span.RecordError(err, trace.WithAttributes(attribute.String("exception.stacktrace", stack)))
+	span.SetStatus(codes.Error, err.Error())
+	LoggerFromContext(ctx).Error(ctx, "Recovered panic in HTTP handler",
+		zap.Error(err),
+		zap.String("panic_stack", stack),
+	)
+
+	if !w.wroteHeader {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serveWithContext serves r with ctx and copies the pattern matched by a downstream mux
+// back to r, so outer middleware can still report the route, even if next panics
+func serveWithContext(next http.Handler, w http.ResponseWriter, r *http.Request, ctx context.Context) {
+	req := r.WithContext(ctx)
+	defer func() { r.Pattern = req.Pattern }()
+	next.ServeHTTP(w, req)
+}
+
+// errorResponse is the JSON envelope written by WriteErrorWithTrace
+type errorResponse struct {
+	Error   string `json:"error"`
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// WriteErrorWithTrace writes a JSON error response carrying the ID of the trace in ctx,
+// both in the X-Trace-Id header and the body, so clients can quote it to support
+func WriteErrorWithTrace(ctx context.Context, w http.ResponseWriter, status int, msg string) {
+	traceID := ""
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		traceID = spanCtx.TraceID().String()
+		w.Header().Set(TraceIDHeader, traceID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: msg, TraceID: traceID})
+}
@@ -0,0 +1,325 @@
+package observability
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareRecoversPanic(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	tracer, recorder := NewTestTracer()
+	provider := NewObservabilityProvider(logger, tracer, nil, "test", "1.0.0")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	provider.Middleware(mux).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	var logged bool
+	for _, entry := range logEntries(t, buf) {
+		if entry["level"] != "error" {
+			continue
+		}
+		logged = true
+		if stack, _ := entry["panic_stack"].(string); !strings.Contains(stack, "middleware_test.go") {
+			t.Errorf("panic_stack = %q, want the panicking handler", stack)
+		}
+		if entry["http.target"] != "/users/42" {
+			t.Errorf("http.target = %v, want the request-scoped logger", entry["http.target"])
+		}
+	}
+	if !logged {
+		t.Error("panic was not logged at error level")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want Error", span.Status().Code)
+	}
+	if span.Name() != "GET /users/{id}" {
+		t.Errorf("span name = %q, want the matched route", span.Name())
+	}
+	var route string
+	for _, kv := range span.Attributes() {
+		if kv.Key == "http.route" {
+			route = kv.Value.AsString()
+		}
+	}
+	if route != "GET /users/{id}" {
+		t.Errorf("http.route = %q, want the matched route", route)
+	}
+}
+
+func TestMetricsHTTPMiddleware(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	handler := m.HTTPMiddleware(mux)
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	metrics := collect(t, reader)
+	requests, ok := metrics[httpServerRequestsMetric].Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s not recorded as a counter", httpServerRequestsMetric)
+	}
+	counts := make(map[int64]int64)
+	for _, dp := range requests.DataPoints {
+		if route, _ := dp.Attributes.Value("http.route"); route.AsString() != "GET /users/{id}" {
+			t.Errorf("http.route = %q, want the matched pattern", route.AsString())
+		}
+		if method, _ := dp.Attributes.Value("http.method"); method.AsString() != http.MethodGet {
+			t.Errorf("http.method = %q, want GET", method.AsString())
+		}
+		status, _ := dp.Attributes.Value("http.status_code")
+		counts[status.AsInt64()] += dp.Value
+	}
+	if counts[http.StatusOK] != 2 || counts[http.StatusNotFound] != 1 {
+		t.Errorf("requests by status = %v, want 2 OK and 1 Not Found", counts)
+	}
+
+	duration, ok := metrics[httpServerDurationMetric].Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("%s not recorded as a histogram", httpServerDurationMetric)
+	}
+	var recorded uint64
+	for _, dp := range duration.DataPoints {
+		recorded += dp.Count
+	}
+	if recorded != 3 {
+		t.Errorf("histogram recorded %d requests, want 3", recorded)
+	}
+
+	active, ok := metrics[httpServerActiveRequestsMetric].Data.(metricdata.Sum[int64])
+	if !ok || len(active.DataPoints) != 1 || active.DataPoints[0].Value != 0 {
+		t.Errorf("active requests = %+v, want back to 0", active.DataPoints)
+	}
+}
+
+func TestMetricsHTTPMiddlewareCountsPanics(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	handler := m.HTTPMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() { _ = recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	requests, ok := collect(t, reader)[httpServerRequestsMetric].Data.(metricdata.Sum[int64])
+	if !ok || len(requests.DataPoints) != 1 {
+		t.Fatalf("requests = %+v, want the panicking request counted", requests)
+	}
+	if status, _ := requests.DataPoints[0].Attributes.Value("http.status_code"); status.AsInt64() != http.StatusInternalServerError {
+		t.Errorf("http.status_code = %d, want %d", status.AsInt64(), http.StatusInternalServerError)
+	}
+}
+
+// hijackableRecorder is a response recorder that can also be hijacked
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestStatusRecorderKeepsOptionalInterfaces(t *testing.T) {
+	underlying := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := http.ResponseWriter(newStatusRecorder(underlying))
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("recorder does not implement http.Flusher")
+	}
+	flusher.Flush()
+	if !underlying.Flushed {
+		t.Error("Flush did not reach the wrapped writer")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		t.Fatal("recorder does not implement http.Hijacker")
+	}
+	if _, _, err := hijacker.Hijack(); err != nil || !underlying.hijacked {
+		t.Errorf("Hijack() = %v, want the wrapped writer hijacked", err)
+	}
+
+	readerFrom, ok := w.(io.ReaderFrom)
+	if !ok {
+		t.Fatal("recorder does not implement io.ReaderFrom")
+	}
+	if _, err := readerFrom.ReadFrom(strings.NewReader("streamed")); err != nil || underlying.Body.String() != "streamed" {
+		t.Errorf("ReadFrom() = %v, body %q, want the body copied", err, underlying.Body.String())
+	}
+
+	// Writers that can't be hijacked report it instead of panicking
+	if _, _, err := newStatusRecorder(httptest.NewRecorder()).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijack() on a plain writer = %v, want http.ErrNotSupported", err)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{"incoming header", "req-123"},
+		{"generated", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger(t, nil)
+			tracer, recorder := NewTestTracer()
+			provider := NewObservabilityProvider(logger, tracer, nil, "test", "1.0.0")
+
+			var fromContext, fromBaggage string
+			handler := tracer.HTTPMiddleware(provider.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = RequestIDFromContext(r.Context())
+				fromBaggage = baggage.FromContext(r.Context()).Member(string(RequestIDKey)).Value()
+				logger.Info(r.Context(), "handled")
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			if requestID == "" || (tt.incoming != "" && requestID != tt.incoming) {
+				t.Fatalf("response %s = %q, want %q or a generated ID", RequestIDHeader, requestID, tt.incoming)
+			}
+			if fromContext != requestID || fromBaggage != requestID {
+				t.Errorf("context has %q, baggage has %q, want %q", fromContext, fromBaggage, requestID)
+			}
+			entries := logEntries(t, buf)
+			if len(entries) != 1 || entries[0][string(RequestIDKey)] != requestID {
+				t.Errorf("entries = %v, want the request ID field", entries)
+			}
+			attrs := attribute.NewSet(recorder.Ended()[0].Attributes()...)
+			if v, _ := attrs.Value(RequestIDKey); v.AsString() != requestID {
+				t.Errorf("span %s = %q, want %q", RequestIDKey, v.AsString(), requestID)
+			}
+		})
+	}
+}
+
+func TestProviderMiddleware(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	tracer, recorder := NewTestTracer()
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	provider := NewObservabilityProvider(logger, tracer, m, "test", "1.0.0")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info(r.Context(), "order loaded")
+	})
+
+	w := httptest.NewRecorder()
+	provider.Middleware(mux).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/7", nil))
+
+	requestID := w.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatalf("response has no %s header", RequestIDHeader)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].SpanKind() != trace.SpanKindServer {
+		t.Fatalf("got spans %v, want one server span", spans)
+	}
+	span := spans[0]
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry[string(RequestIDKey)] != requestID || entry["http.target"] != "/orders/7" {
+		t.Errorf("entry = %v, want the request ID and request-scoped fields", entry)
+	}
+	if entry["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("trace_id = %v, want the server span's trace", entry["trace_id"])
+	}
+
+	requests, ok := collect(t, reader)[httpServerRequestsMetric].Data.(metricdata.Sum[int64])
+	if !ok || len(requests.DataPoints) != 1 || requests.DataPoints[0].Value != 1 {
+		t.Fatalf("%s = %+v, want one request counted", httpServerRequestsMetric, requests.DataPoints)
+	}
+	if route, _ := requests.DataPoints[0].Attributes.Value("http.route"); route.AsString() != "GET /orders/{id}" {
+		t.Errorf("http.route = %q, want the matched pattern", route.AsString())
+	}
+}
+
+func TestWriteErrorWithTrace(t *testing.T) {
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	rec := httptest.NewRecorder()
+	WriteErrorWithTrace(ctx, rec, http.StatusBadGateway, "upstream unavailable")
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+	traceID := spanCtx.TraceID().String()
+	if got := rec.Header().Get(TraceIDHeader); got != traceID {
+		t.Errorf("%s = %q, want %q", TraceIDHeader, got, traceID)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	if body["error"] != "upstream unavailable" || body["trace_id"] != traceID {
+		t.Errorf("body = %v", body)
+	}
+
+	// Without a span the header and trace ID are omitted
+	rec = httptest.NewRecorder()
+	WriteErrorWithTrace(context.Background(), rec, http.StatusBadRequest, "bad request")
+	if got := rec.Header().Get(TraceIDHeader); got != "" {
+		t.Errorf("%s = %q without a span", TraceIDHeader, got)
+	}
+	if strings.Contains(rec.Body.String(), "trace_id") {
+		t.Errorf("body %q has a trace ID without a span", rec.Body.String())
+	}
+}
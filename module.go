@@ -3,11 +3,15 @@ package observability
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -16,40 +20,94 @@ import (
 	"go.uber.org/zap"
 )
 
+// defaultShutdownTimeout bounds the cleanup returned by the initializers unless configured otherwise
+const defaultShutdownTimeout = 5 * time.Second
+
 // InitializeObservabilityProvider initializes all observability components properly
 func InitializeObservabilityProvider(ctx context.Context, logConfig *LogConfig, tracingConfig *TracingConfig, metricsConfig *MetricsConfig) (*ObservabilityProvider, func(), error) {
+	return initializeObservabilityProvider(ctx, logConfig, tracingConfig, metricsConfig, defaultShutdownTimeout)
+}
+
+// InitializeObservabilityProviderFromConfig initializes all observability components from a
+// single configuration, bounding the returned cleanup by its ShutdownTimeout
+func InitializeObservabilityProviderFromConfig(ctx context.Context, config *ObservabilityConfig) (*ObservabilityProvider, func(), error) {
+	shutdownTimeout := config.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	return initializeObservabilityProvider(ctx, &config.Logging, &config.Tracing, &config.Metrics, shutdownTimeout)
+}
+
+// initializeObservabilityProvider initializes all components with a cleanup bounded by shutdownTimeout
+func initializeObservabilityProvider(ctx context.Context, logConfig *LogConfig, tracingConfig *TracingConfig, metricsConfig *MetricsConfig, shutdownTimeout time.Duration) (*ObservabilityProvider, func(), error) {
+	// Resolve one instance ID shared by all signals
+	instanceID := tracingConfig.ServiceInstanceID
+	if instanceID == "" {
+		instanceID = metricsConfig.ServiceInstanceID
+	}
+	instanceID = resolveInstanceID(instanceID)
+
+	// Resolve the deployment slot the same way
+	slot := tracingConfig.DeploymentSlot
+	if slot == "" {
+		slot = metricsConfig.DeploymentSlot
+	}
+	slot = resolveDeploymentSlot(slot)
+
+	tracingConfigCopy := *tracingConfig
+	tracingConfigCopy.ServiceInstanceID = instanceID
+	tracingConfigCopy.DeploymentSlot = slot
+	tracingConfig = &tracingConfigCopy
+
+	metricsConfigCopy := *metricsConfig
+	metricsConfigCopy.ServiceInstanceID = instanceID
+	metricsConfigCopy.DeploymentSlot = slot
+	metricsConfig = &metricsConfigCopy
+
 	// Initialize logger
 	logger, err := NewLogger(logConfig)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to initialize logger: %w", err)
 	}
+	logger = logger.With(zap.String(string(semconv.ServiceInstanceIDKey), instanceID))
+	if slot != "" {
+		logger = logger.With(zap.String(string(DeploymentSlotKey), slot))
+	}
+
+	// Initialize metrics first so tracing can derive span metrics from them
+	metrics, err := NewMetrics(ctx, *metricsConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
 
 	// Initialize tracer
-	tracer, tracerShutdown, err := setupTracing(ctx, tracingConfig)
+	tracer, tracerShutdown, err := setupTracing(ctx, tracingConfig, metrics)
 	if err != nil {
+		metrics.Shutdown(ctx)
 		return nil, nil, fmt.Errorf("failed to initialize tracer: %w", err)
 	}
 
-	// Initialize metrics
-	metrics, err := NewMetrics(ctx, *metricsConfig)
-	if err != nil {
-		tracerShutdown(ctx)
-		return nil, nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	stopFlushSignal := func() {}
+	if metricsConfig.FlushOnSignal && metrics.enabled {
+		stopFlushSignal = watchFlushSignal(metrics, logger)
 	}
 
 	// Create cleanup function
 	cleanup := func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
-		if err := metrics.Shutdown(ctx); err != nil {
-			logger.Error(ctx, "Error shutting down metrics", zap.Error(err))
-		}
+		stopFlushSignal()
 
+		// Shut down tracing first so span metrics recorded while flushing are exported
 		if err := tracerShutdown(ctx); err != nil {
 			logger.Error(ctx, "Error shutting down tracer", zap.Error(err))
 		}
 
+		if err := metrics.Shutdown(ctx); err != nil {
+			logger.Error(ctx, "Error shutting down metrics", zap.Error(err))
+		}
+
 		if err := logger.Sync(); err != nil {
 			fmt.Printf("Error syncing logger: %v\n", err)
 		}
@@ -62,15 +120,19 @@ func InitializeObservabilityProvider(ctx context.Context, logConfig *LogConfig,
 		Metrics:        metrics,
 		serviceName:    tracingConfig.ServiceName,
 		serviceVersion: tracingConfig.ServiceVersion,
+		environment:    tracingConfig.Environment,
 	}, cleanup, nil
 }
 
 // setupTracing initializes the OpenTelemetry tracer provider
-func setupTracing(ctx context.Context, config *TracingConfig) (*Tracer, func(context.Context) error, error) {
+func setupTracing(ctx context.Context, config *TracingConfig, metrics *Metrics) (*Tracer, func(context.Context) error, error) {
 	if !config.Enabled {
 		// Return a no-op tracer when disabled
-		tracer := NewTracer(config.ServiceName)
-		return tracer, func(context.Context) error { return nil }, nil
+		return newNoopTracing(config)
+	}
+
+	if err := validateCompression(config.Compression); err != nil {
+		return nil, nil, err
 	}
 
 	// Create resource
@@ -79,55 +141,204 @@ func setupTracing(ctx context.Context, config *TracingConfig) (*Tracer, func(con
 			semconv.ServiceNameKey.String(config.ServiceName),
 			semconv.ServiceVersionKey.String(config.ServiceVersion),
 			semconv.DeploymentEnvironmentKey.String(config.Environment),
+			semconv.ServiceInstanceIDKey.String(resolveInstanceID(config.ServiceInstanceID)),
 		),
+		resource.WithAttributes(deploymentSlotAttributes(config.DeploymentSlot)...),
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create OTLP exporter
-	client := otlptracegrpc.NewClient(
-		otlptracegrpc.WithEndpoint(config.Endpoint),
-		otlptracegrpc.WithInsecure(),
-	)
+	// Export to the injected exporter, or else to every collector
+	var exporters, created []sdktrace.SpanExporter
+	// No provider owns the exporters created here until setup succeeds
+	shutdownCreated := func() {
+		for _, exporter := range created {
+			// The setup failure is the error worth reporting
+			_ = exporter.Shutdown(ctx)
+		}
+	}
+	if config.SpanExporter != nil {
+		exporters = append(exporters, config.SpanExporter)
+	} else {
+		for _, endpoint := range collectorEndpoints(config) {
+			exporter, err := newSpanExporter(ctx, config, endpoint)
+			if err != nil {
+				shutdownCreated()
+				if config.FailOpen {
+					otel.Handle(fmt.Errorf("failed to create span exporter, tracing disabled: %w", err))
+					return newNoopTracing(config)
+				}
+				return nil, nil, fmt.Errorf("failed to create span exporter: %w", err)
+			}
+			exporters = append(exporters, exporter)
+			created = append(created, exporter)
+		}
+	}
 
-	exporter, err := otlptrace.New(ctx, client)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	// Each exporter gets its own batcher so a slow one doesn't hold up the others
+	var processors []sdktrace.SpanProcessor
+	for i, exporter := range exporters {
+		if config.CircuitBreaker != nil {
+			exporter = &breakerSpanExporter{SpanExporter: exporter, breaker: newCircuitBreaker(*config.CircuitBreaker)}
+		}
+		// Spool outside the breaker so batches it skips are kept too
+		if config.SpoolDir != "" {
+			spool, err := NewFileSpoolExporter(exporter, spoolDir(config.SpoolDir, i, len(exporters)))
+			if err != nil {
+				shutdownCreated()
+				return nil, nil, err
+			}
+			exporter = spool
+		}
+		processors = append(processors, sdktrace.NewBatchSpanProcessor(exporter))
+	}
+
+	// Print spans synchronously so they show up as soon as they end
+	if config.ConsoleExporter {
+		writer := config.ConsoleWriter
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(writer), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			shutdownCreated()
+			return nil, nil, fmt.Errorf("failed to create console exporter: %w", err)
+		}
+		processors = append(processors, sdktrace.NewSimpleSpanProcessor(exporter))
+	}
+
+	// Sanitize attributes before any exporter sees them, masking before truncating
+	// so a cut can't leave part of a value the sanitizer would have matched
+	var sanitizers sanitizerChain
+	if config.Sanitizer != nil {
+		sanitizers = append(sanitizers, config.Sanitizer)
+	}
+	if config.MaxAttributeValueLength > 0 {
+		sanitizers = append(sanitizers, truncatingSanitizer{max: config.MaxAttributeValueLength})
+	}
+	if len(sanitizers) > 0 {
+		for i, processor := range processors {
+			processors[i] = newSanitizingProcessor(processor, sanitizers)
+		}
+	}
+
+	// Only export spans that were slow or failed
+	if config.MinSpanDuration > 0 {
+		for i, processor := range processors {
+			processors[i] = newLatencyGateProcessor(processor, config.MinSpanDuration)
+		}
+	}
+
+	// Derive span duration metrics when metrics are being exported
+	if config.EmitSpanMetrics && metrics != nil && metrics.enabled {
+		processor, err := newSpanMetricsProcessor(metrics)
+		if err != nil {
+			shutdownCreated()
+			return nil, nil, fmt.Errorf("failed to create span metrics processor: %w", err)
+		}
+		processors = append(processors, processor)
+	}
+
+	// The adaptive sampler watches spans end to follow the error rate
+	var adaptive *adaptiveSampler
+	if config.AdaptiveSampling != nil {
+		adaptive = newAdaptiveSampler(*config.AdaptiveSampling, config.SamplingRate)
+		processors = append(processors, adaptive)
 	}
 
 	// Create a sampler
-	var sampler sdktrace.Sampler
-	if config.SamplingRate >= 1.0 {
-		sampler = sdktrace.AlwaysSample()
-	} else if config.SamplingRate <= 0.0 {
-		sampler = sdktrace.NeverSample()
-	} else {
-		sampler = sdktrace.TraceIDRatioBased(config.SamplingRate)
+	sampler, err := newSampler(config, adaptive)
+	if err != nil {
+		shutdownCreated()
+		return nil, nil, fmt.Errorf("failed to create sampler: %w", err)
 	}
 
 	// Create and register the trace provider
-	tp := sdktrace.NewTracerProvider(
+	options := []sdktrace.TracerProviderOption{
 		sdktrace.WithSampler(sampler),
-		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(res),
-	)
-
-	otel.SetTracerProvider(tp)
+	}
+	for _, processor := range processors {
+		options = append(options, sdktrace.WithSpanProcessor(processor))
+	}
+	tp := sdktrace.NewTracerProvider(options...)
 
-	// Set global propagator
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	// Register the provider and propagator globally unless asked to stay local
+	if !config.DisableGlobalProvider {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		))
+	}
 
-	// Create our custom tracer
-	tracer := NewTracer(config.ServiceName)
+	// Create our custom tracer on our own provider, which may not be the global one
+	tracer := NewTracerFromProvider(tp, config.ServiceName, trace.WithInstrumentationVersion(config.ServiceVersion))
 
 	// Return tracer and shutdown function
 	return tracer, tp.Shutdown, nil
 }
 
+// newNoopTracing returns a tracer that records nothing unless a provider is registered elsewhere
+func newNoopTracing(config *TracingConfig) (*Tracer, func(context.Context) error, error) {
+	tracer := NewTracer(config.ServiceName)
+	return tracer, func(context.Context) error { return nil }, nil
+}
+
+// collectorEndpoints lists the endpoints spans are exported to. An empty entry
+// means the exporter's default; no entries means only the console exporter is used.
+func collectorEndpoints(config *TracingConfig) []string {
+	if config.Endpoint == "" && len(config.Endpoints) > 0 {
+		return config.Endpoints
+	}
+	if config.Endpoint == "" && config.ConsoleExporter {
+		return nil
+	}
+	return append([]string{config.Endpoint}, config.Endpoints...)
+}
+
+// newSpanExporter creates the collector exporter selected by the tracing configuration for an endpoint
+func newSpanExporter(ctx context.Context, config *TracingConfig, endpoint string) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case "", ExporterOTLP:
+		return otlptrace.New(ctx, otlptracegrpc.NewClient(traceExporterOptions(config, endpoint)...))
+	case ExporterZipkin:
+		return zipkin.New(endpoint)
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", config.Exporter)
+	}
+}
+
+// traceExporterOptions builds the OTLP exporter options for the tracing configuration and an endpoint
+func traceExporterOptions(config *TracingConfig, endpoint string) []otlptracegrpc.Option {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithInsecure()}
+
+	// A URL endpoint lets its scheme decide whether the connection is secure
+	endpoint = resolveEndpoint(endpoint, envOTLPTracesEndpoint)
+	if strings.Contains(endpoint, "://") {
+		options = append(options, otlptracegrpc.WithEndpointURL(endpoint))
+	} else {
+		options = append(options, otlptracegrpc.WithEndpoint(endpoint))
+	}
+
+	if config.Retry != nil {
+		retry := config.Retry.withDefaults()
+		options = append(options, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         retry.Enabled,
+			InitialInterval: retry.InitialInterval,
+			MaxInterval:     retry.MaxInterval,
+			MaxElapsedTime:  retry.MaxElapsedTime,
+		}))
+	}
+
+	if config.Compression == CompressionGzip {
+		options = append(options, otlptracegrpc.WithCompressor(CompressionGzip))
+	}
+
+	return options
+}
+
 // GetTraceID extracts trace ID from context
 func GetTraceID(ctx context.Context) string {
 	spanCtx := trace.SpanContextFromContext(ctx)
@@ -145,3 +356,8 @@ func GetSpanID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// IsSampled reports whether the span in the context is sampled, e.g. to do extra work only for traced requests
+func IsSampled(ctx context.Context) bool {
+	return trace.SpanContextFromContext(ctx).IsSampled()
+}
@@ -0,0 +1,683 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+func TestConsoleSpanExporter(t *testing.T) {
+	buf := &syncBuffer{}
+	ctx := context.Background()
+
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		DisableGlobalProvider: true,
+		ServiceName:           "console",
+		SamplingRate:          1,
+		ConsoleExporter:       true,
+		ConsoleWriter:         buf,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = shutdown(ctx) }()
+
+	_, span := tracer.Start(ctx, "checkout")
+	span.End()
+
+	if out := buf.String(); !strings.Contains(out, `"Name": "checkout"`) {
+		t.Errorf("console output = %q, want the pretty-printed span", out)
+	}
+}
+
+func TestConsoleMetricExporter(t *testing.T) {
+	buf := &syncBuffer{}
+	ctx := context.Background()
+
+	metrics, err := NewMetrics(ctx, MetricsConfig{
+		Enabled:               true,
+		DisableGlobalProvider: true,
+		ServiceName:           "console",
+		ConsoleExporter:       true,
+		ConsoleWriter:         buf,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = metrics.Shutdown(ctx) }()
+
+	if err := metrics.IncrementCounter(ctx, "orders_placed", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := buf.String(); !strings.Contains(out, `"Name": "orders_placed"`) {
+		t.Errorf("console output = %q, want the pretty-printed counter", out)
+	}
+}
+
+// fakeTraceCollector is an OTLP trace collector rejecting the first failures exports as unavailable
+// and recording the names of the spans it accepts
+type fakeTraceCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	mu       sync.Mutex
+	failures int
+	attempts int
+	names    []string
+}
+
+func (c *fakeTraceCollector) Export(_ context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.attempts <= c.failures {
+		return nil, status.Error(grpccodes.Unavailable, "collector starting")
+	}
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.names = append(c.names, span.Name)
+			}
+		}
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// spanNames returns the names of the spans accepted so far
+func (c *fakeTraceCollector) spanNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.names...)
+}
+
+// startTraceCollector serves collector on a local port, returning its address
+func startTraceCollector(t *testing.T, collector *fakeTraceCollector, opts ...grpc.ServerOption) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	collectortrace.RegisterTraceServiceServer(server, collector)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestTraceExporterRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		retry    *RetryConfig
+		wantErr  bool
+		attempts int
+	}{
+		{"enabled retries until the collector is ready", &RetryConfig{Enabled: true, InitialInterval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, MaxElapsedTime: 5 * time.Second}, false, 3},
+		{"disabled gives up after one attempt", &RetryConfig{Enabled: false}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			collector := &fakeTraceCollector{failures: 2}
+			endpoint := startTraceCollector(t, collector)
+
+			exporter, err := newSpanExporter(ctx, &TracingConfig{Retry: tt.retry}, endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = exporter.Shutdown(ctx) }()
+
+			err = exporter.ExportSpans(ctx, spanStubs("cold-start"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExportSpans() = %v, want error %v", err, tt.wantErr)
+			}
+			if collector.attempts != tt.attempts {
+				t.Errorf("collector saw %d attempts, want %d", collector.attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestTracingExportsToEveryEndpoint(t *testing.T) {
+	ctx := context.Background()
+	old, migrated := &fakeTraceCollector{}, &fakeTraceCollector{}
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		DisableGlobalProvider: true,
+		SamplingRate:          1,
+		Endpoint:              startTraceCollector(t, old),
+		Endpoints:             []string{startTraceCollector(t, migrated)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"checkout", "payment"} {
+		_, span := tracer.Start(ctx, name)
+		span.End()
+	}
+	// Shutdown must flush every batcher
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"checkout", "payment"}
+	for name, collector := range map[string]*fakeTraceCollector{"old": old, "migrated": migrated} {
+		if got := collector.spanNames(); !slices.Equal(got, want) {
+			t.Errorf("%s collector received %v, want %v", name, got, want)
+		}
+	}
+}
+
+// compressionRecorder is a gRPC stats handler recording the compression of incoming requests
+type compressionRecorder struct {
+	mu          sync.Mutex
+	compression []string
+}
+
+func (r *compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if header, ok := s.(*stats.InHeader); ok {
+		r.mu.Lock()
+		r.compression = append(r.compression, header.Compression)
+		r.mu.Unlock()
+	}
+}
+
+func (r *compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func TestTraceExporterCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		want        string
+	}{
+		{"default", "", ""},
+		{"none", CompressionNone, ""},
+		{"gzip", CompressionGzip, "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			recorder := &compressionRecorder{}
+			endpoint := startTraceCollector(t, &fakeTraceCollector{}, grpc.StatsHandler(recorder))
+
+			exporter, err := newSpanExporter(ctx, &TracingConfig{Compression: tt.compression}, endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = exporter.Shutdown(ctx) }()
+			if err := exporter.ExportSpans(ctx, spanStubs("upload")); err != nil {
+				t.Fatal(err)
+			}
+
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			if !slices.Equal(recorder.compression, []string{tt.want}) {
+				t.Errorf("collector saw compression %q, want %q", recorder.compression, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownCompressionRejected(t *testing.T) {
+	ctx := context.Background()
+	if _, _, err := setupTracing(ctx, &TracingConfig{Enabled: true, Compression: "brotli"}, nil); err == nil {
+		t.Error("setupTracing accepted an unknown compression")
+	}
+	if _, err := NewMetrics(ctx, MetricsConfig{Enabled: true, Compression: "brotli"}); err == nil {
+		t.Error("NewMetrics accepted an unknown compression")
+	}
+}
+
+func TestRetryConfigDefaults(t *testing.T) {
+	got := RetryConfig{Enabled: true, MaxInterval: time.Second}.withDefaults()
+	want := RetryConfig{
+		Enabled:         true,
+		InitialInterval: defaultRetryInitialInterval,
+		MaxInterval:     time.Second,
+		MaxElapsedTime:  defaultRetryMaxElapsedTime,
+	}
+	if got != want {
+		t.Errorf("withDefaults() = %+v, want %+v", got, want)
+	}
+}
+
+// spanStubs creates read-only spans with the given names
+func spanStubs(names ...string) []sdktrace.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, len(names))
+	for i, name := range names {
+		stubs[i] = tracetest.SpanStub{Name: name, Attributes: []attribute.KeyValue{attribute.Int("index", i)}}
+	}
+	return stubs.Snapshots()
+}
+
+func TestFailOpen(t *testing.T) {
+	ctx := context.Background()
+	// The gRPC client rejects the endpoint when the exporter is created
+	const badEndpoint = "%%bad"
+
+	for _, failOpen := range []bool{false, true} {
+		tracer, _, err := setupTracing(ctx, &TracingConfig{
+			Enabled:               true,
+			DisableGlobalProvider: true,
+			Endpoint:              badEndpoint,
+			FailOpen:              failOpen,
+		}, nil)
+		if !failOpen {
+			if err == nil {
+				t.Error("setupTracing() without FailOpen succeeded, want the exporter error")
+			}
+		} else if err != nil {
+			t.Errorf("setupTracing() with FailOpen = %v, want a no-op tracer", err)
+		} else {
+			_, span := tracer.Start(ctx, "noop")
+			span.End()
+		}
+
+		metrics, err := NewMetrics(ctx, MetricsConfig{
+			Enabled:               true,
+			DisableGlobalProvider: true,
+			Endpoint:              badEndpoint,
+			FailOpen:              failOpen,
+		})
+		if !failOpen {
+			if err == nil {
+				t.Error("NewMetrics() without FailOpen succeeded, want the exporter error")
+			}
+		} else if err != nil {
+			t.Errorf("NewMetrics() with FailOpen = %v, want no-op metrics", err)
+		} else if err := metrics.IncrementCounter(ctx, "requests", 1); err != nil {
+			t.Errorf("IncrementCounter() on no-op metrics = %v", err)
+		}
+	}
+}
+
+func TestZipkinExporter(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		body []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		ServiceName:           "legacy",
+		SamplingRate:          1,
+		Exporter:              ExporterZipkin,
+		Endpoint:              server.URL + "/api/v2/spans",
+		DisableGlobalProvider: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tracer.Start(ctx, "legacy-call")
+	span.End()
+	// Shutting down flushes the batch to the collector
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var spans []struct {
+		Name          string `json:"name"`
+		LocalEndpoint struct {
+			ServiceName string `json:"serviceName"`
+		} `json:"localEndpoint"`
+	}
+	if err := json.Unmarshal(body, &spans); err != nil {
+		t.Fatalf("decode %q: %v", body, err)
+	}
+	if len(spans) != 1 || spans[0].Name != "legacy-call" || spans[0].LocalEndpoint.ServiceName != "legacy" {
+		t.Errorf("zipkin received %+v, want the legacy-call span", spans)
+	}
+}
+
+// initializeTestProvider initializes a provider logging to a buffer and exporting to in-memory fakes.
+// The provider is cleaned up when the test ends.
+func initializeTestProvider(t *testing.T, tracing TracingConfig, metrics MetricsConfig) (*ObservabilityProvider, *syncBuffer, *tracetest.InMemoryExporter, *flakyMetricExporter) {
+	t.Helper()
+	buf := &syncBuffer{}
+	spans := tracetest.NewInMemoryExporter()
+	metricExporter := &flakyMetricExporter{}
+
+	tracing.Enabled, tracing.SamplingRate, tracing.DisableGlobalProvider, tracing.SpanExporter = true, 1, true, spans
+	metrics.Enabled, metrics.DisableGlobalProvider, metrics.MetricExporter = true, true, metricExporter
+	provider, cleanup, err := InitializeObservabilityProvider(context.Background(), &LogConfig{Writer: buf}, &tracing, &metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	return provider, buf, spans, metricExporter
+}
+
+// resourceValues initializes a provider, emits a span, a metric and a log entry, and returns
+// the value of key on each signal's resource or log entry, or nil where it is missing
+func resourceValues(t *testing.T, tracing TracingConfig, metrics MetricsConfig, key attribute.Key) map[string]interface{} {
+	t.Helper()
+	provider, buf, spans, metricExporter := initializeTestProvider(t, tracing, metrics)
+
+	ctx := context.Background()
+	_, span := provider.Tracer.Start(ctx, "work")
+	span.End()
+	if err := provider.Metrics.IncrementCounter(ctx, "jobs", 1); err != nil {
+		t.Fatal(err)
+	}
+	provider.Logger.Info(ctx, "done")
+	if err := provider.Tracer.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Metrics.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]interface{})
+	if v, ok := spans.GetSpans()[0].Resource.Set().Value(key); ok {
+		values["span"] = v.AsString()
+	}
+	metricExporter.mu.Lock()
+	if v, ok := metricExporter.exported[0].Resource.Set().Value(key); ok {
+		values["metric"] = v.AsString()
+	}
+	metricExporter.mu.Unlock()
+	if v, ok := logEntries(t, buf)[0][string(key)]; ok {
+		values["log"] = v
+	}
+	return values
+}
+
+// assertOnAllSignals fails unless values holds want for every signal, or no signal when want is empty
+func assertOnAllSignals(t *testing.T, values map[string]interface{}, want string) {
+	t.Helper()
+	for _, signal := range []string{"span", "metric", "log"} {
+		got, ok := values[signal]
+		if want == "" && ok {
+			t.Errorf("%s has %v, want no value", signal, got)
+		}
+		if want != "" && got != want {
+			t.Errorf("%s has %v, want %q", signal, got, want)
+		}
+	}
+}
+
+func TestServiceInstanceIDSharedAcrossSignals(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit string
+		env      string
+		want     string
+	}{
+		{"generated", "", "", processInstanceID},
+		{"env", "", "replica-env", "replica-env"},
+		{"config", "replica-config", "replica-env", "replica-config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envServiceInstanceID, tt.env)
+			values := resourceValues(t, TracingConfig{ServiceInstanceID: tt.explicit}, MetricsConfig{}, semconv.ServiceInstanceIDKey)
+			assertOnAllSignals(t, values, tt.want)
+		})
+	}
+}
+
+func TestNonGlobalProvidersDontInterfere(t *testing.T) {
+	ctx := context.Background()
+	globalTracers := otel.GetTracerProvider()
+	globalMeters := otel.GetMeterProvider()
+	globalPropagator := otel.GetTextMapPropagator()
+
+	type instance struct {
+		spans   *tracetest.InMemoryExporter
+		metrics *flakyMetricExporter
+		tracer  *Tracer
+		meter   *Metrics
+	}
+	newInstance := func(name string) instance {
+		spans := tracetest.NewInMemoryExporter()
+		tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+			Enabled:               true,
+			ServiceName:           name,
+			SamplingRate:          1,
+			DisableGlobalProvider: true,
+			SpanExporter:          spans,
+		}, nil)
+		if err != nil {
+			t.Fatalf("setupTracing(%s): %v", name, err)
+		}
+		t.Cleanup(func() { _ = shutdown(ctx) })
+
+		exporter := &flakyMetricExporter{}
+		metrics, err := NewMetrics(ctx, MetricsConfig{
+			Enabled:               true,
+			ServiceName:           name,
+			DisableGlobalProvider: true,
+			MetricExporter:        exporter,
+		})
+		if err != nil {
+			t.Fatalf("NewMetrics(%s): %v", name, err)
+		}
+		t.Cleanup(func() { _ = metrics.Shutdown(ctx) })
+		return instance{spans: spans, metrics: exporter, tracer: tracer, meter: metrics}
+	}
+
+	first, second := newInstance("first"), newInstance("second")
+
+	for _, inst := range []instance{first, second} {
+		_, span := inst.tracer.Start(ctx, inst.tracer.GetName()+"-span")
+		span.End()
+		if err := inst.tracer.ForceFlush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := inst.meter.IncrementCounter(ctx, inst.tracer.GetName()+"_requests", 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := inst.meter.ForceFlush(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		inst  instance
+		name  string
+		other string
+	}{{first, "first", "second"}, {second, "second", "first"}} {
+		spans := tc.inst.spans.GetSpans()
+		if len(spans) != 1 || spans[0].Name != tc.name+"-span" {
+			t.Errorf("%s exported spans %v, want only its own", tc.name, spans.Snapshots())
+		}
+
+		if _, ok := tc.inst.metrics.counter(tc.name + "_requests"); !ok {
+			t.Errorf("%s did not export its counter", tc.name)
+		}
+		if _, ok := tc.inst.metrics.counter(tc.other + "_requests"); ok {
+			t.Errorf("%s exported the %s counter", tc.name, tc.other)
+		}
+	}
+
+	if otel.GetTracerProvider() != globalTracers {
+		t.Error("global tracer provider was replaced")
+	}
+	if otel.GetMeterProvider() != globalMeters {
+		t.Error("global meter provider was replaced")
+	}
+	if otel.GetTextMapPropagator() != globalPropagator {
+		t.Error("global propagator was replaced")
+	}
+}
+
+// deadlineSpanExporter records the time left before the deadline of its shutdown context
+type deadlineSpanExporter struct {
+	*tracetest.InMemoryExporter
+	remaining time.Duration
+}
+
+func (e *deadlineSpanExporter) Shutdown(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		e.remaining = time.Until(deadline)
+	}
+	return e.InMemoryExporter.Shutdown(ctx)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured time.Duration
+		want       time.Duration
+	}{
+		{"configured", 30 * time.Second, 30 * time.Second},
+		{"default", 0, defaultShutdownTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &deadlineSpanExporter{InMemoryExporter: tracetest.NewInMemoryExporter()}
+			_, cleanup, err := InitializeObservabilityProviderFromConfig(context.Background(), &ObservabilityConfig{
+				Logging:         LogConfig{Writer: &syncBuffer{}},
+				Tracing:         TracingConfig{Enabled: true, DisableGlobalProvider: true, SpanExporter: exporter},
+				ShutdownTimeout: tt.configured,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cleanup()
+
+			// Allow for the time spent shutting down before the exporter
+			if exporter.remaining <= tt.want-time.Second || exporter.remaining > tt.want {
+				t.Errorf("exporter shut down with %v left, want about %v", exporter.remaining, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectedExporters(t *testing.T) {
+	ctx := context.Background()
+	// An endpoint the OTLP exporters would reject proves they aren't built
+	const badEndpoint = "%%bad"
+
+	metricExporter := &flakyMetricExporter{}
+	metrics, err := NewMetrics(ctx, MetricsConfig{
+		Enabled:               true,
+		Endpoint:              badEndpoint,
+		DisableGlobalProvider: true,
+		MetricExporter:        metricExporter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = metrics.Shutdown(ctx) })
+	if err := metrics.IncrementCounter(ctx, "orders", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if total, ok := metricExporter.counter("orders"); !ok || total != 2 {
+		t.Errorf("injected metric exporter got orders = %d, %v, want 2", total, ok)
+	}
+
+	spanExporter := tracetest.NewInMemoryExporter()
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		SamplingRate:          1,
+		Endpoint:              badEndpoint,
+		DisableGlobalProvider: true,
+		SpanExporter:          spanExporter,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = shutdown(ctx) })
+	_, span := tracer.Start(ctx, "place order")
+	span.End()
+	if err := tracer.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if spans := spanExporter.GetSpans(); len(spans) != 1 || spans[0].Name != "place order" {
+		t.Errorf("injected span exporter got %v, want the span", spans)
+	}
+}
+
+func TestDeploymentSlotOnAllSignals(t *testing.T) {
+	tests := []struct {
+		name    string
+		tracing string
+		metrics string
+		env     string
+		want    string
+	}{
+		{"unset", "", "", "", ""},
+		{"env", "", "", "green", "green"},
+		{"metrics config", "", "blue", "green", "blue"},
+		{"tracing config", "green", "blue", "", "green"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envDeploymentSlot, tt.env)
+			values := resourceValues(t, TracingConfig{DeploymentSlot: tt.tracing}, MetricsConfig{DeploymentSlot: tt.metrics}, DeploymentSlotKey)
+			assertOnAllSignals(t, values, tt.want)
+		})
+	}
+}
+
+func TestInstrumentationScopeVersion(t *testing.T) {
+	provider, _, spans, metrics := initializeTestProvider(t,
+		TracingConfig{ServiceName: "orders", ServiceVersion: "3.1.4"},
+		MetricsConfig{ServiceName: "orders", ServiceVersion: "3.1.4"})
+	ctx := context.Background()
+
+	_, span := provider.Tracer.Start(ctx, "work")
+	span.End()
+	if err := provider.Metrics.IncrementCounter(ctx, "jobs", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Tracer.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Metrics.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if scope := spans.GetSpans()[0].InstrumentationScope; scope.Name != "orders" || scope.Version != "3.1.4" {
+		t.Errorf("span scope = %+v, want the service name and version", scope)
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if scope := metrics.exported[0].ScopeMetrics[0].Scope; scope.Name != "orders" || scope.Version != "3.1.4" {
+		t.Errorf("metric scope = %+v, want the service name and version", scope)
+	}
+}
@@ -0,0 +1,75 @@
+package observability
+
+import (
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// ComputePercentiles approximates the given percentiles, from 0 to 100, of a histogram data
+// point collected with e.g. a manual reader, for local debugging without a backend. Values
+// are interpolated linearly within buckets, using the recorded min and max as the outer
+// edges of the first and last buckets when available. Percentiles outside 0-100 and all
+// percentiles of an empty data point are omitted from the result.
+func ComputePercentiles(data metricdata.HistogramDataPoint[float64], ps ...float64) map[float64]float64 {
+	percentiles := make(map[float64]float64, len(ps))
+	if data.Count == 0 || len(data.BucketCounts) != len(data.Bounds)+1 {
+		return percentiles
+	}
+
+	min, hasMin := data.Min.Value()
+	max, hasMax := data.Max.Value()
+
+	for _, p := range ps {
+		if p < 0 || p > 100 {
+			continue
+		}
+		rank := p / 100 * float64(data.Count)
+
+		var cumulative float64
+		for i, count := range data.BucketCounts {
+			if count == 0 {
+				continue
+			}
+			if cumulative+float64(count) < rank {
+				cumulative += float64(count)
+				continue
+			}
+
+			lower, upper := bucketEdges(data.Bounds, i, min, hasMin, max, hasMax)
+			fraction := (rank - cumulative) / float64(count)
+			percentiles[p] = lower + (upper-lower)*fraction
+			break
+		}
+	}
+	return percentiles
+}
+
+// bucketEdges returns the range of values in bucket i, narrowed to the recorded min and
+// max when available. Without them the unbounded edges of the outer buckets collapse to
+// the adjacent bound.
+func bucketEdges(bounds []float64, i int, min float64, hasMin bool, max float64, hasMax bool) (float64, float64) {
+	var lower, upper float64
+	switch {
+	case len(bounds) == 0:
+		lower, upper = min, max
+	case i == 0:
+		lower, upper = bounds[0], bounds[0]
+		if hasMin {
+			lower = min
+		}
+	case i == len(bounds):
+		lower, upper = bounds[i-1], bounds[i-1]
+		if hasMax {
+			upper = max
+		}
+	default:
+		lower, upper = bounds[i-1], bounds[i]
+	}
+
+	if hasMin && min > lower {
+		lower = min
+	}
+	if hasMax && max < upper {
+		upper = max
+	}
+	return lower, upper
+}
@@ -0,0 +1,58 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestComputePercentiles(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	bounds := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	if err := m.Register([]InstrumentSpec{{Kind: HistogramInstrument, Name: "latency", Unit: "ms", Buckets: bounds}}); err != nil {
+		t.Fatal(err)
+	}
+	// A uniform distribution from 1 to 100
+	for v := 1; v <= 100; v++ {
+		if err := m.RecordHistogram(context.Background(), "latency", float64(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	point := collect(t, reader)["latency"].Data.(metricdata.Histogram[float64]).DataPoints[0]
+
+	got := ComputePercentiles(point, 0, 50, 90, 99, 100, 150)
+	want := map[float64][2]float64{
+		0:   {1, 1},
+		50:  {45, 55},
+		90:  {85, 95},
+		99:  {95, 100},
+		100: {100, 100},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got percentiles %v, want %v and out-of-range ones omitted", got, want)
+	}
+	for p, bounds := range want {
+		if v, ok := got[p]; !ok || v < bounds[0] || v > bounds[1] {
+			t.Errorf("p%g = %v, want within %v", p, v, bounds)
+		}
+	}
+}
+
+func TestComputePercentilesEdgeCases(t *testing.T) {
+	if got := ComputePercentiles(metricdata.HistogramDataPoint[float64]{}, 50); len(got) != 0 {
+		t.Errorf("empty point gave %v, want no percentiles", got)
+	}
+
+	// Everything above the last bound is interpolated up to the recorded max
+	point := metricdata.HistogramDataPoint[float64]{
+		Count:        4,
+		Bounds:       []float64{1},
+		BucketCounts: []uint64{0, 4},
+		Min:          metricdata.NewExtrema(2.0),
+		Max:          metricdata.NewExtrema(10.0),
+	}
+	if got := ComputePercentiles(point, 50)[50]; got != 6 {
+		t.Errorf("p50 = %v, want 6 halfway between the min and max", got)
+	}
+}
@@ -0,0 +1,70 @@
+package observability
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanDurationMetric is the histogram span durations are recorded to
+const spanDurationMetric = "span_duration_seconds"
+
+// spanMetricsProcessor records the duration of every ended span into a histogram
+type spanMetricsProcessor struct {
+	histogram metric.Float64Histogram
+}
+
+// newSpanMetricsProcessor creates a processor recording span durations to the given metrics
+func newSpanMetricsProcessor(metrics *Metrics) (*spanMetricsProcessor, error) {
+	histogram, err := metrics.CreateHistogram(spanDurationMetric, "Duration of spans", "s")
+	if err != nil {
+		return nil, err
+	}
+	return &spanMetricsProcessor{histogram: histogram}, nil
+}
+
+// OnStart does nothing; durations are only known once the span ends
+func (p *spanMetricsProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd records the span duration tagged by span name and status
+func (p *spanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	duration := s.EndTime().Sub(s.StartTime()).Seconds()
+	p.histogram.Record(context.Background(), duration, metric.WithAttributes(
+		attribute.String("span.name", s.Name()),
+		attribute.String("status", s.Status().Code.String()),
+	))
+}
+
+// Shutdown does nothing; the histogram is flushed with the metrics pipeline
+func (p *spanMetricsProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing; the histogram is flushed with the metrics pipeline
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// latencyGateProcessor only passes ended spans to the next processor if they took at
+// least the minimum duration or failed, keeping slow and failed operations only
+type latencyGateProcessor struct {
+	sdktrace.SpanProcessor
+	minDuration time.Duration
+}
+
+// newLatencyGateProcessor wraps next so it only sees slow or failed spans
+func newLatencyGateProcessor(next sdktrace.SpanProcessor, minDuration time.Duration) sdktrace.SpanProcessor {
+	return &latencyGateProcessor{SpanProcessor: next, minDuration: minDuration}
+}
+
+// OnEnd drops fast spans that did not fail
+func (p *latencyGateProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.EndTime().Sub(s.StartTime()) < p.minDuration && s.Status().Code != codes.Error {
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
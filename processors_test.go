@@ -0,0 +1,93 @@
+package observability
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpanMetricsProcessor(t *testing.T) {
+	ctx := context.Background()
+	metrics, reader := newTestMetrics(t, MetricsConfig{})
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		SamplingRate:          1,
+		EmitSpanMetrics:       true,
+		DisableGlobalProvider: true,
+		SpanExporter:          tracetest.NewInMemoryExporter(),
+	}, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = shutdown(ctx) }()
+
+	start := time.Now()
+	_, span := tracer.StartAt(ctx, "charge", start)
+	span.SetStatus(codes.Error, "declined")
+	tracer.EndAt(span, start.Add(250*time.Millisecond))
+
+	histogram, ok := collect(t, reader)[spanDurationMetric].Data.(metricdata.Histogram[float64])
+	if !ok || len(histogram.DataPoints) != 1 {
+		t.Fatalf("%s points = %+v, want one series", spanDurationMetric, histogram.DataPoints)
+	}
+	point := histogram.DataPoints[0]
+	if point.Count != 1 || point.Sum != 0.25 {
+		t.Errorf("recorded count %d sum %g, want one 0.25s span", point.Count, point.Sum)
+	}
+	if v, _ := point.Attributes.Value("span.name"); v.AsString() != "charge" {
+		t.Errorf("span.name = %q, want charge", v.AsString())
+	}
+	if v, _ := point.Attributes.Value("status"); v.AsString() != codes.Error.String() {
+		t.Errorf("status = %q, want %q", v.AsString(), codes.Error.String())
+	}
+}
+
+func TestLatencyGateProcessor(t *testing.T) {
+	ctx := context.Background()
+	spans := tracetest.NewInMemoryExporter()
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		SamplingRate:          1,
+		DisableGlobalProvider: true,
+		SpanExporter:          spans,
+		MinSpanDuration:       100 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = shutdown(ctx) })
+
+	start := time.Now().Add(-time.Second)
+	for _, tt := range []struct {
+		name     string
+		duration time.Duration
+		failed   bool
+	}{
+		{"fast", 10 * time.Millisecond, false},
+		{"at threshold", 100 * time.Millisecond, false},
+		{"slow", 250 * time.Millisecond, false},
+		{"fast failure", 10 * time.Millisecond, true},
+	} {
+		_, span := tracer.StartAt(ctx, tt.name, start)
+		if tt.failed {
+			span.SetStatus(codes.Error, "timeout")
+		}
+		tracer.EndAt(span, start.Add(tt.duration))
+	}
+	if err := tracer.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var exported []string
+	for _, span := range spans.GetSpans() {
+		exported = append(exported, span.Name)
+	}
+	if want := []string{"at threshold", "slow", "fast failure"}; !slices.Equal(exported, want) {
+		t.Errorf("exported %v, want %v", exported, want)
+	}
+}
@@ -1,26 +1,148 @@
 package observability
 
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
 // ObservabilityProvider provides unified access to all observability components (logging, tracing, metrics)
 type ObservabilityProvider struct {
-	Logger         *Logger
-	Tracer         *Tracer
-	Metrics        *Metrics
+	Logger         LoggerInterface
+	Tracer         TracerInterface
+	Metrics        MetricsInterface
 	serviceName    string
 	serviceVersion string
+	environment    string
 }
 
-// NewObservabilityProvider creates a new observability provider with all components
+// NewObservabilityProvider creates a new observability provider with all components.
+// The components can be replaced with mocks afterwards through the provider's fields.
 func NewObservabilityProvider(
 	logger *Logger,
 	tracer *Tracer,
 	metrics *Metrics,
 	serviceName, serviceVersion string,
 ) *ObservabilityProvider {
-	return &ObservabilityProvider{
-		Logger:         logger,
-		Tracer:         tracer,
-		Metrics:        metrics,
+	p := &ObservabilityProvider{
 		serviceName:    serviceName,
 		serviceVersion: serviceVersion,
 	}
+	// Leave missing components nil rather than holding typed nil pointers
+	if logger != nil {
+		p.Logger = logger
+	}
+	if tracer != nil {
+		p.Tracer = tracer
+	}
+	if metrics != nil {
+		p.Metrics = metrics
+	}
+	return p
+}
+
+// ServiceName returns the name of the service being observed
+func (p *ObservabilityProvider) ServiceName() string {
+	return p.serviceName
+}
+
+// ServiceVersion returns the version of the service being observed
+func (p *ObservabilityProvider) ServiceVersion() string {
+	return p.serviceVersion
+}
+
+// Environment returns the deployment environment of the service, which is only
+// known for providers created by InitializeObservabilityProvider
+func (p *ObservabilityProvider) Environment() string {
+	return p.environment
+}
+
+// ForTenant returns a provider scoped to a tenant that shares this provider's exporters.
+// Its logs, spans and metrics carry the tenant.id attribute and its metric names are
+// prefixed with the tenant followed by a dot.
+func (p *ObservabilityProvider) ForTenant(tenant string) *ObservabilityProvider {
+	c := *p
+	if p.Logger != nil {
+		c.Logger = p.Logger.Child("", TenantIDField(tenant))
+	}
+	if p.Tracer != nil {
+		c.Tracer = p.Tracer.WithAttributes(TenantID(tenant))
+	}
+	if p.Metrics != nil {
+		c.Metrics = p.Metrics.Scoped(tenant+".", TenantID(tenant))
+	}
+	return &c
+}
+
+// heartbeatMetric reports 1 for as long as the process runs
+const heartbeatMetric = "service_up"
+
+// EnableHeartbeat registers the service_up gauge, which always reports 1 tagged with
+// the service name and version, for liveness dashboards. Nothing is exported when metrics are disabled.
+func (p *ObservabilityProvider) EnableHeartbeat() error {
+	if p.Metrics == nil {
+		return nil
+	}
+	service := p.Metrics.Scoped("",
+		semconv.ServiceNameKey.String(p.serviceName),
+		semconv.ServiceVersionKey.String(p.serviceVersion),
+	)
+	_, err := service.CreateGauge(heartbeatMetric, "Reports 1 while the service is running", func() float64 { return 1 })
+	return err
+}
+
+// Audit records who did what to the audit sink. Audit entries are written at a fixed
+// level regardless of the configured log level and are never sampled.
+func (p *ObservabilityProvider) Audit(ctx context.Context, action string, fields ...zap.Field) {
+	if logger, ok := p.Logger.(*Logger); ok {
+		// Report the caller of Audit rather than this method
+		logger.writeAudit(ctx, 2, action, fields...)
+		return
+	}
+	p.Logger.Audit(ctx, action, fields...)
+}
+
+// BackgroundContext starts a root span for a background operation such as a cron or worker job.
+// Entries logged with the returned context carry a job field; the returned func ends the span.
+func (p *ObservabilityProvider) BackgroundContext(operation string) (context.Context, func()) {
+	ctx := ContextWithFields(context.Background(), zap.String("job", operation))
+	if p.Tracer == nil {
+		return ctx, func() {}
+	}
+	ctx, span := p.Tracer.Start(ctx, operation)
+	return ctx, func() {
+		span.End()
+	}
+}
+
+// Event logs a message at the given level and records it as an event on the active span with the same fields
+func (p *ObservabilityProvider) Event(ctx context.Context, level LogLevel, msg string, fields ...zap.Field) {
+	// Add the span event first since a fatal log exits the process
+	trace.SpanFromContext(ctx).AddEvent(msg, trace.WithAttributes(attributesFromFields(fields)...))
+	if logger, ok := p.Logger.(*Logger); ok {
+		// Report the caller of Event rather than this method
+		logger.log(ctx, level, 2, msg, fields...)
+		return
+	}
+	switch level {
+	case DebugLevel:
+		p.Logger.Debug(ctx, msg, fields...)
+	case WarnLevel:
+		p.Logger.Warn(ctx, msg, fields...)
+	case ErrorLevel:
+		p.Logger.Error(ctx, msg, fields...)
+	case FatalLevel:
+		p.Logger.Fatal(ctx, msg, fields...)
+	default:
+		p.Logger.Info(ctx, msg, fields...)
+	}
+}
+
+// ForComponent returns a logger named after the component and tagged with it,
+// along with the attributes that scope spans and metrics to the same component
+func (p *ObservabilityProvider) ForComponent(name string) (LoggerInterface, []attribute.KeyValue) {
+	return p.Logger.Child(name, ComponentField(name)), []attribute.KeyValue{Component(name)}
 }
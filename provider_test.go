@@ -0,0 +1,438 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestBackgroundContext(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	tracer, recorder := NewTestTracer()
+	provider := NewObservabilityProvider(logger, tracer, nil, "test", "1.0.0")
+
+	ctx, done := provider.BackgroundContext("nightly-report")
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatal("background context has no valid span")
+	}
+	logger.Info(ctx, "report generated")
+	if len(recorder.Ended()) != 0 {
+		t.Fatal("span ended before cleanup")
+	}
+	done()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "nightly-report" {
+		t.Fatalf("ended spans = %d, want the operation's span", len(spans))
+	}
+	if spans[0].Parent().IsValid() {
+		t.Error("background span is not a root span")
+	}
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["job"] != "nightly-report" {
+		t.Errorf("entries = %v, want the job field", entries)
+	}
+}
+
+func TestBackgroundContextWithoutTracer(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	provider := NewObservabilityProvider(logger, nil, nil, "test", "1.0.0")
+
+	ctx, done := provider.BackgroundContext("nightly-report")
+	logger.Info(ctx, "report generated")
+	done()
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["job"] != "nightly-report" {
+		t.Errorf("entries = %v, want the job field", entries)
+	}
+}
+
+func TestEventLogsAndRecordsSpanEvent(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	tracer, recorder := NewTestTracer()
+	provider := NewObservabilityProvider(logger, tracer, nil, "test", "1.0.0")
+
+	ctx, span := tracer.Start(context.Background(), "lookup")
+	provider.Event(ctx, WarnLevel, "cache miss", zap.String("key", "user:42"), zap.Int("attempt", 2))
+	span.End()
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry["level"] != "warn" || entry["message"] != "cache miss" || entry["key"] != "user:42" || entry["attempt"] != float64(2) {
+		t.Errorf("entry = %v, want the warning with its fields", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "provider_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 1 || events[0].Name != "cache miss" {
+		t.Fatalf("events = %v, want the cache miss event", events)
+	}
+	attrs := attribute.NewSet(events[0].Attributes...)
+	if v, _ := attrs.Value("key"); v.AsString() != "user:42" {
+		t.Errorf("event key = %q, want user:42", v.AsString())
+	}
+	if v, _ := attrs.Value("attempt"); v.AsInt64() != 2 {
+		t.Errorf("event attempt = %d, want 2", v.AsInt64())
+	}
+}
+
+func TestForComponent(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	provider := NewObservabilityProvider(logger, nil, nil, "test", "1.0.0")
+
+	componentLogger, attrs := provider.ForComponent("billing")
+	componentLogger.Info(context.Background(), "invoice sent")
+
+	entries := logEntries(t, buf)
+	if len(entries) != 1 || entries[0]["logger"] != "billing" || entries[0][string(ComponentKey)] != "billing" {
+		t.Errorf("entries = %v, want the logger named and tagged billing", entries)
+	}
+	if len(attrs) != 1 || attrs[0] != Component("billing") {
+		t.Errorf("attrs = %v, want the component attribute", attrs)
+	}
+}
+
+func TestEnableHeartbeat(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	provider := NewObservabilityProvider(nil, nil, m, "checkout", "1.2.3")
+
+	if err := provider.EnableHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+
+	gauge, ok := collect(t, reader)[heartbeatMetric].Data.(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("got %+v, want one gauge point", gauge)
+	}
+	point := gauge.DataPoints[0]
+	if point.Value != 1 {
+		t.Errorf("%s = %g, want 1", heartbeatMetric, point.Value)
+	}
+	want := attribute.NewSet(attribute.String("service.name", "checkout"), attribute.String("service.version", "1.2.3"))
+	if !point.Attributes.Equals(&want) {
+		t.Errorf("attributes = %v, want the service name and version", point.Attributes.ToSlice())
+	}
+}
+
+func TestEnableHeartbeatWithMetricsDisabled(t *testing.T) {
+	m, err := NewMetrics(context.Background(), MetricsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewObservabilityProvider(nil, nil, m, "checkout", "1.2.3")
+
+	if err := provider.EnableHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if names := m.RegisteredInstruments(); len(names) != 0 {
+		t.Errorf("registered %v, want nothing while disabled", names)
+	}
+}
+
+func TestAuditBypassesLevelAndSampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		auditPaths bool
+	}{
+		{"dedicated sink", true},
+		{"main outputs", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &LogConfig{
+				Level:    ErrorLevel,
+				Sampling: &LogSamplingConfig{Initial: 1, Thereafter: 1000},
+			}
+			auditPath := filepath.Join(t.TempDir(), "audit.log")
+			if tt.auditPaths {
+				config.AuditOutputPaths = []string{auditPath}
+			}
+			logger, buf := newTestLogger(t, config)
+			provider := NewObservabilityProvider(logger, nil, nil, "test", "1.0.0")
+			ctx := context.Background()
+
+			logger.Info(ctx, "below the level")
+			fields := make([]zap.Field, 1, 4)
+			fields[0] = zap.String("user", "alice")
+			for i := 0; i < 3; i++ {
+				provider.Audit(ctx, "role granted", fields...)
+			}
+			if len(fields[:2][1].Key) != 0 {
+				t.Errorf("Audit wrote %v into the caller's backing array", fields[:2][1])
+			}
+			_ = logger.Sync()
+
+			auditBuf := buf
+			if tt.auditPaths {
+				data, err := os.ReadFile(auditPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				auditBuf = &syncBuffer{}
+				auditBuf.Write(data)
+				if buf.String() != "" {
+					t.Errorf("main output got %q, want nothing", buf.String())
+				}
+			}
+			entries := logEntries(t, auditBuf)
+			if len(entries) != 3 {
+				t.Fatalf("got %d audit entries, want every one", len(entries))
+			}
+			for _, entry := range entries {
+				if entry["message"] != "role granted" || entry["user"] != "alice" || entry["audit"] != true || entry["level"] != "info" {
+					t.Errorf("entry = %v, want the audit entry at info", entry)
+				}
+				if caller, _ := entry["caller"].(string); !strings.Contains(caller, "provider_test.go") {
+					t.Errorf("caller = %q, want the test file", caller)
+				}
+			}
+		})
+	}
+}
+
+func TestProviderServiceMetadata(t *testing.T) {
+	provider := NewObservabilityProvider(nil, nil, nil, "checkout", "1.2.3")
+	if provider.ServiceName() != "checkout" || provider.ServiceVersion() != "1.2.3" || provider.Environment() != "" {
+		t.Errorf("got %q %q %q, want the name and version passed in and no environment",
+			provider.ServiceName(), provider.ServiceVersion(), provider.Environment())
+	}
+
+	initialized, _, _, _ := initializeTestProvider(t,
+		TracingConfig{ServiceName: "orders", ServiceVersion: "2.0.0", Environment: "staging"}, MetricsConfig{})
+	if initialized.ServiceName() != "orders" || initialized.ServiceVersion() != "2.0.0" || initialized.Environment() != "staging" {
+		t.Errorf("got %q %q %q, want the configured name, version and environment",
+			initialized.ServiceName(), initialized.ServiceVersion(), initialized.Environment())
+	}
+}
+
+func TestForTenant(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+	tracer, recorder := NewTestTracer()
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	provider := NewObservabilityProvider(logger, tracer, m, "gateway", "1.0.0")
+	ctx := context.Background()
+
+	tenants := []string{"acme", "globex"}
+	for _, tenant := range tenants {
+		scoped := provider.ForTenant(tenant)
+		if err := scoped.Metrics.IncrementCounter(ctx, "requests", 1); err != nil {
+			t.Fatal(err)
+		}
+		_, span := scoped.Tracer.Start(ctx, "route")
+		span.End()
+		scoped.Logger.Info(ctx, "routed")
+	}
+
+	metrics := collect(t, reader)
+	entries := logEntries(t, buf)
+	spans := recorder.Ended()
+	for i, tenant := range tenants {
+		sum, ok := metrics[tenant+".requests"].Data.(metricdata.Sum[int64])
+		if !ok || len(sum.DataPoints) != 1 {
+			t.Fatalf("%s.requests = %+v, want one point", tenant, metrics[tenant+".requests"])
+		}
+		if v, _ := sum.DataPoints[0].Attributes.Value(TenantIDKey); v.AsString() != tenant {
+			t.Errorf("%s.requests %s = %q, want %q", tenant, TenantIDKey, v.AsString(), tenant)
+		}
+		if entries[i][string(TenantIDKey)] != tenant {
+			t.Errorf("log entry %v, want %s %q", entries[i], TenantIDKey, tenant)
+		}
+		attrs := attribute.NewSet(spans[i].Attributes()...)
+		if v, _ := attrs.Value(TenantIDKey); v.AsString() != tenant {
+			t.Errorf("span %s = %q, want %q", TenantIDKey, v.AsString(), tenant)
+		}
+	}
+	if _, ok := metrics["requests"]; ok {
+		t.Error("tenant metrics were also recorded unprefixed")
+	}
+}
+
+// loggedEntry is an entry received by mockLogger
+type loggedEntry struct {
+	level  LogLevel
+	msg    string
+	fields []zap.Field
+}
+
+// mockLogger records the entries logged through it. Methods it doesn't override panic.
+type mockLogger struct {
+	LoggerInterface
+	entries *[]loggedEntry
+	fields  []zap.Field
+}
+
+func newMockLogger() *mockLogger {
+	return &mockLogger{entries: &[]loggedEntry{}}
+}
+
+func (m *mockLogger) log(level LogLevel, msg string, fields []zap.Field) {
+	*m.entries = append(*m.entries, loggedEntry{level: level, msg: msg, fields: append(append([]zap.Field{}, m.fields...), fields...)})
+}
+
+func (m *mockLogger) Debug(_ context.Context, msg string, fields ...zap.Field) {
+	m.log(DebugLevel, msg, fields)
+}
+func (m *mockLogger) Info(_ context.Context, msg string, fields ...zap.Field) {
+	m.log(InfoLevel, msg, fields)
+}
+func (m *mockLogger) Warn(_ context.Context, msg string, fields ...zap.Field) {
+	m.log(WarnLevel, msg, fields)
+}
+func (m *mockLogger) Error(_ context.Context, msg string, fields ...zap.Field) {
+	m.log(ErrorLevel, msg, fields)
+}
+func (m *mockLogger) Fatal(_ context.Context, msg string, fields ...zap.Field) {
+	m.log(FatalLevel, msg, fields)
+}
+func (m *mockLogger) Audit(_ context.Context, action string, fields ...zap.Field) {
+	m.log(InfoLevel, action, append(fields, zap.Bool("audit", true)))
+}
+func (m *mockLogger) Child(_ string, fields ...zap.Field) LoggerInterface {
+	return &mockLogger{entries: m.entries, fields: append(append([]zap.Field{}, m.fields...), fields...)}
+}
+func (m *mockLogger) Sync() error { return nil }
+
+// hasField reports whether fields contain the key with the string value
+func hasField(fields []zap.Field, key, value string) bool {
+	for _, f := range fields {
+		if f.Key == key && f.String == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProviderWithMockLogger(t *testing.T) {
+	mock := newMockLogger()
+	provider := NewObservabilityProvider(nil, NewTracer("test"), nil, "svc", "1.0.0")
+	provider.Logger = mock
+	ctx := context.Background()
+
+	provider.Event(ctx, WarnLevel, "disk low")
+	provider.Audit(ctx, "user.deleted")
+	provider.ForTenant("acme").Logger.Info(ctx, "tenant scoped")
+	logger, _ := provider.ForComponent("billing")
+	logger.Error(ctx, "component scoped")
+
+	entries := *mock.entries
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	if entries[0].level != WarnLevel || entries[0].msg != "disk low" {
+		t.Errorf("event = %+v", entries[0])
+	}
+	if entries[1].msg != "user.deleted" || len(entries[1].fields) != 1 || entries[1].fields[0] != zap.Bool("audit", true) {
+		t.Errorf("audit = %+v", entries[1])
+	}
+	if !hasField(entries[2].fields, string(TenantIDKey), "acme") {
+		t.Errorf("tenant entry fields = %v, want tenant.id", entries[2].fields)
+	}
+	if !hasField(entries[3].fields, string(ComponentKey), "billing") {
+		t.Errorf("component entry fields = %v, want component", entries[3].fields)
+	}
+}
+
+// mockMetrics records the calls made through MetricsInterface. Methods it doesn't override panic.
+type mockMetrics struct {
+	MetricsInterface
+	counters map[string]int64
+	gauges   []string
+	scopes   []string
+}
+
+func newMockMetrics() *mockMetrics {
+	return &mockMetrics{counters: make(map[string]int64)}
+}
+
+func (m *mockMetrics) IncrementCounter(_ context.Context, name string, value int64, _ ...attribute.KeyValue) error {
+	m.counters[name] += value
+	return nil
+}
+func (m *mockMetrics) CreateGauge(name, _ string, _ func() float64) (metric.Float64ObservableGauge, error) {
+	m.gauges = append(m.gauges, name)
+	return noop.Float64ObservableGauge{}, nil
+}
+func (m *mockMetrics) Scoped(prefix string, _ ...attribute.KeyValue) MetricsInterface {
+	m.scopes = append(m.scopes, prefix)
+	return m
+}
+func (m *mockMetrics) HTTPMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+// mockTracer records the names of the spans started through TracerInterface. Methods it doesn't override panic.
+type mockTracer struct {
+	TracerInterface
+	started []string
+}
+
+func (m *mockTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	m.started = append(m.started, name)
+	return ctx, trace.SpanFromContext(ctx)
+}
+func (m *mockTracer) WithAttributes(...attribute.KeyValue) TracerInterface {
+	return m
+}
+func (m *mockTracer) HTTPMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func TestProviderWithMockMetricsAndTracer(t *testing.T) {
+	metrics := newMockMetrics()
+	tracer := &mockTracer{}
+	provider := NewObservabilityProvider(nil, nil, nil, "svc", "1.0.0")
+	provider.Logger = newMockLogger()
+	provider.Metrics = metrics
+	provider.Tracer = tracer
+
+	if err := provider.EnableHeartbeat(); err != nil {
+		t.Fatalf("EnableHeartbeat: %v", err)
+	}
+	if len(metrics.gauges) != 1 || metrics.gauges[0] != heartbeatMetric {
+		t.Errorf("gauges = %v, want the heartbeat", metrics.gauges)
+	}
+
+	tenant := provider.ForTenant("acme")
+	if err := tenant.Metrics.IncrementCounter(context.Background(), "orders", 2); err != nil {
+		t.Fatalf("IncrementCounter: %v", err)
+	}
+	if metrics.counters["orders"] != 2 {
+		t.Errorf("counters = %v, want orders incremented through the tenant provider", metrics.counters)
+	}
+	if len(metrics.scopes) != 2 || metrics.scopes[1] != "acme." {
+		t.Errorf("scopes = %v, want the heartbeat's and the tenant's", metrics.scopes)
+	}
+
+	_, end := provider.BackgroundContext("nightly")
+	end()
+	if len(tracer.started) != 1 || tracer.started[0] != "nightly" {
+		t.Errorf("started spans = %v, want the background job", tracer.started)
+	}
+
+	handler := provider.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want the handler's", rec.Code)
+	}
+}
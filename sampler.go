@@ -0,0 +1,271 @@
+package observability
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newSampler builds the sampler described by the tracing configuration. A non-nil
+// adaptive sampler replaces the fixed SamplingRate. The configured samplers only decide
+// for root spans; child spans follow their parent so traces are kept or dropped whole.
+func newSampler(config *TracingConfig, adaptive *adaptiveSampler) (sdktrace.Sampler, error) {
+	var sampler sdktrace.Sampler
+	if adaptive != nil {
+		sampler = adaptive
+	} else if config.SamplingRate >= 1.0 {
+		sampler = sdktrace.AlwaysSample()
+	} else if config.SamplingRate <= 0.0 {
+		sampler = sdktrace.NeverSample()
+	} else {
+		sampler = sdktrace.TraceIDRatioBased(config.SamplingRate)
+	}
+
+	// Only decisions made by the ratio carry it, not those forced by a rule
+	if config.RecordSamplingRatio {
+		if adaptive != nil {
+			// The adaptive ratio changes over time, so only the sampler knows the one it used
+			adaptive.recordRatio = true
+		} else {
+			sampler = &ratioAttributeSampler{delegate: sampler, ratio: math.Max(0, math.Min(1, config.SamplingRate))}
+		}
+	}
+
+	if config.MaxSpansPerSecond > 0 {
+		sampler = newRateLimitingSampler(sampler, config.MaxSpansPerSecond)
+	}
+
+	if len(config.SamplingRules) > 0 {
+		for _, rule := range config.SamplingRules {
+			if _, err := path.Match(rule.Pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid sampling rule pattern %q: %w", rule.Pattern, err)
+			}
+		}
+		sampler = &ruleBasedSampler{rules: config.SamplingRules, fallback: sampler}
+	}
+
+	return sdktrace.ParentBased(sampler), nil
+}
+
+// samplingRatioKey is the root span attribute holding the ratio the span was sampled at
+const samplingRatioKey = "sampling.ratio"
+
+// ratioAttributeSampler adds the fixed sampling ratio to sampled root spans
+type ratioAttributeSampler struct {
+	delegate sdktrace.Sampler
+	ratio    float64
+}
+
+// ShouldSample returns the delegate's decision, adding the ratio attribute for root spans
+func (s *ratioAttributeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.delegate.ShouldSample(p)
+	if !trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		result.Attributes = append(result.Attributes, attribute.Float64(samplingRatioKey, s.ratio))
+	}
+	return result
+}
+
+// Description returns a human-readable name for the sampler
+func (s *ratioAttributeSampler) Description() string {
+	return s.delegate.Description()
+}
+
+// adaptiveSampler samples by trace ID at a ratio that follows the error rate of ended
+// spans. It is also registered as a span processor so it sees spans end, which only
+// sampled spans do, so the error rate is estimated from the sampled spans alone.
+type adaptiveSampler struct {
+	config AdaptiveSamplingConfig
+	now    func() time.Time
+	// recordRatio adds the ratio each root span was sampled at to it, see RecordSamplingRatio
+	recordRatio bool
+
+	mu          sync.Mutex
+	windowStart time.Time
+	spans       int
+	failed      int
+	errorRate   float64
+	ratio       float64
+}
+
+// newAdaptiveSampler creates an adaptive sampler starting at its floor
+func newAdaptiveSampler(config AdaptiveSamplingConfig, samplingRate float64) *adaptiveSampler {
+	config = config.withDefaults(samplingRate)
+	return &adaptiveSampler{
+		config:      config,
+		now:         time.Now,
+		windowStart: time.Now(),
+		ratio:       config.Floor,
+	}
+}
+
+// ShouldSample samples the trace if its ID falls within the current ratio, so every
+// service sampling at the same ratio makes the same decision
+func (s *adaptiveSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	ratio := s.currentRatio()
+	// Compare the low 63 bits of the trace ID to the ratio, as TraceIDRatioBased does
+	threshold := uint64(ratio * (1 << 63))
+	result := sdktrace.SamplingResult{
+		Decision:   sdktrace.Drop,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+	if binary.BigEndian.Uint64(p.TraceID[8:16])>>1 < threshold {
+		result.Decision = sdktrace.RecordAndSample
+	}
+	if s.recordRatio {
+		result.Attributes = []attribute.KeyValue{attribute.Float64(samplingRatioKey, ratio)}
+	}
+	return result
+}
+
+// Description returns a human-readable name for the sampler
+func (s *adaptiveSampler) Description() string {
+	return fmt.Sprintf("Adaptive{%g,%g}", s.config.Floor, s.config.Ceiling)
+}
+
+// currentRatio returns the sampling ratio, re-evaluating it if the window has elapsed
+func (s *adaptiveSampler) currentRatio() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.windowStart) < s.config.Window {
+		return s.ratio
+	}
+
+	observed := 0.0
+	if s.spans > 0 {
+		observed = float64(s.failed) / float64(s.spans)
+	}
+	s.errorRate = (s.errorRate + observed) / 2
+	s.spans, s.failed = 0, 0
+	s.windowStart = now
+
+	scale := math.Min(1, s.errorRate/s.config.TargetErrorRate)
+	s.ratio = s.config.Floor + (s.config.Ceiling-s.config.Floor)*scale
+	return s.ratio
+}
+
+// OnStart does nothing; failures are only known once the span ends
+func (s *adaptiveSampler) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd counts the span towards the current window's error rate
+func (s *adaptiveSampler) OnEnd(span sdktrace.ReadOnlySpan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spans++
+	if span.Status().Code == codes.Error {
+		s.failed++
+	}
+}
+
+// Shutdown does nothing; the sampler holds no resources
+func (s *adaptiveSampler) Shutdown(context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing; the sampler holds no buffered spans
+func (s *adaptiveSampler) ForceFlush(context.Context) error {
+	return nil
+}
+
+// ruleBasedSampler applies the first sampling rule matching the span name, deferring to fallback otherwise
+type ruleBasedSampler struct {
+	rules    []SamplingRule
+	fallback sdktrace.Sampler
+}
+
+// ShouldSample returns the decision of the first matching rule or the fallback sampler
+func (s *ruleBasedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, rule := range s.rules {
+		if matched, _ := path.Match(rule.Pattern, p.Name); !matched {
+			continue
+		}
+
+		decision := sdktrace.Drop
+		if rule.Sample {
+			decision = sdktrace.RecordAndSample
+		}
+		return sdktrace.SamplingResult{
+			Decision:   decision,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	return s.fallback.ShouldSample(p)
+}
+
+// Description returns a human-readable name for the sampler
+func (s *ruleBasedSampler) Description() string {
+	return fmt.Sprintf("RuleBased{%d,%s}", len(s.rules), s.fallback.Description())
+}
+
+// rateLimitingSampler caps the number of sampled root spans per second using a token bucket
+type rateLimitingSampler struct {
+	delegate     sdktrace.Sampler
+	maxPerSecond float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimitingSampler wraps a sampler so that at most maxPerSecond root spans are sampled each second
+func newRateLimitingSampler(delegate sdktrace.Sampler, maxPerSecond int) *rateLimitingSampler {
+	return &rateLimitingSampler{
+		delegate:     delegate,
+		maxPerSecond: float64(maxPerSecond),
+		tokens:       float64(maxPerSecond),
+		last:         time.Now(),
+	}
+}
+
+// ShouldSample consults the delegate and drops its sampled spans once the bucket is empty
+func (s *rateLimitingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.delegate.ShouldSample(p)
+	if result.Decision != sdktrace.RecordAndSample {
+		return result
+	}
+
+	if !s.take() {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	return result
+}
+
+// Description returns a human-readable name for the sampler
+func (s *rateLimitingSampler) Description() string {
+	return fmt.Sprintf("RateLimiting{%g,%s}", s.maxPerSecond, s.delegate.Description())
+}
+
+// take refills the bucket for the elapsed time and consumes a token if one is available
+func (s *rateLimitingSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.tokens += now.Sub(s.last).Seconds() * s.maxPerSecond
+	if s.tokens > s.maxPerSecond {
+		s.tokens = s.maxPerSecond
+	}
+	s.last = now
+
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
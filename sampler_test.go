@@ -0,0 +1,314 @@
+package observability
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newSampledTracer creates a tracer sampling with the sampler built from config
+func newSampledTracer(t *testing.T, config *TracingConfig) (*Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	sampler, err := newSampler(config, nil)
+	if err != nil {
+		t.Fatalf("newSampler: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return NewTracerFromProvider(tp, "test"), recorder
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	tracer, recorder := newSampledTracer(t, &TracingConfig{SamplingRate: 1, MaxSpansPerSecond: 5})
+
+	for i := 0; i < 100; i++ {
+		_, span := tracer.Start(context.Background(), "burst")
+		span.End()
+	}
+
+	// The bucket starts full and may refill by a token while the loop runs
+	if n := len(recorder.Ended()); n < 5 || n > 6 {
+		t.Errorf("recorded %d of 100 spans, want about 5", n)
+	}
+}
+
+func TestRateLimitingSamplerKeepsTracesWhole(t *testing.T) {
+	tracer, recorder := newSampledTracer(t, &TracingConfig{SamplingRate: 1, MaxSpansPerSecond: 1})
+
+	// The single token goes to the first root, whose children all follow it
+	ctx, root := tracer.Start(context.Background(), "request")
+	for i := 0; i < 20; i++ {
+		_, child := tracer.Start(ctx, "query")
+		child.End()
+	}
+	root.End()
+
+	// Once the bucket is empty later roots are dropped along with their children
+	ctx, dropped := tracer.Start(context.Background(), "request")
+	_, child := tracer.Start(ctx, "query")
+	child.End()
+	dropped.End()
+
+	if n := len(recorder.Ended()); n != 21 {
+		t.Errorf("recorded %d spans, want the first trace's 21", n)
+	}
+	if child.SpanContext().IsSampled() {
+		t.Error("child of a rate-limited root was sampled")
+	}
+}
+
+func TestRuleBasedSampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		span     string
+		recorded bool
+	}{
+		{"health check dropped", 1, "/healthz", false},
+		{"other span follows ratio", 1, "GET /orders", true},
+		{"forced span sampled below ratio", 0, "checkout.pay", true},
+		{"other span dropped by ratio", 0, "GET /orders", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := newSampledTracer(t, &TracingConfig{
+				SamplingRate: tt.rate,
+				SamplingRules: []SamplingRule{
+					{Pattern: "/healthz", Sample: false},
+					{Pattern: "checkout.*", Sample: true},
+				},
+			})
+
+			_, span := tracer.Start(context.Background(), tt.span)
+			span.End()
+
+			if got := len(recorder.Ended()) == 1; got != tt.recorded {
+				t.Errorf("recorded = %v, want %v", got, tt.recorded)
+			}
+		})
+	}
+}
+
+func TestRuleBasedSamplerDropsChildrenOfDroppedSpans(t *testing.T) {
+	tracer, recorder := newSampledTracer(t, &TracingConfig{
+		SamplingRate:  1,
+		SamplingRules: []SamplingRule{{Pattern: "/healthz", Sample: false}},
+	})
+
+	ctx, span := tracer.Start(context.Background(), "/healthz")
+	_, query := tracer.Start(ctx, "SELECT 1")
+	query.End()
+	span.End()
+
+	if n := len(recorder.Ended()); n != 0 {
+		t.Errorf("recorded %d spans, want the health check's child dropped too", n)
+	}
+}
+
+func TestRuleBasedSamplerRejectsInvalidPattern(t *testing.T) {
+	_, err := newSampler(&TracingConfig{SamplingRules: []SamplingRule{{Pattern: "[", Sample: true}}}, nil)
+	if err == nil {
+		t.Error("newSampler() accepted a malformed pattern")
+	}
+}
+
+func TestRecordSamplingRatio(t *testing.T) {
+	tracer, recorder := newSampledTracer(t, &TracingConfig{SamplingRate: 0.25, RecordSamplingRatio: true})
+
+	for i := 0; i < 200; i++ {
+		ctx, root := tracer.Start(context.Background(), "root")
+		if root.IsRecording() {
+			_, child := tracer.Start(ctx, "child")
+			child.End()
+		}
+		root.End()
+	}
+
+	var roots int
+	for _, span := range recorder.Ended() {
+		attrs := attribute.NewSet(span.Attributes()...)
+		ratio, ok := attrs.Value(samplingRatioKey)
+		switch span.Name() {
+		case "root":
+			roots++
+			if !ok || ratio.AsFloat64() != 0.25 {
+				t.Errorf("root %s = %v, want 0.25", samplingRatioKey, ratio.Emit())
+			}
+		case "child":
+			if ok {
+				t.Errorf("child has %s = %v, want it on root spans only", samplingRatioKey, ratio.Emit())
+			}
+		}
+	}
+	if roots == 0 {
+		t.Fatal("no root span was sampled")
+	}
+}
+
+func TestRecordSamplingRatioOmittedForRules(t *testing.T) {
+	tracer, recorder := newSampledTracer(t, &TracingConfig{
+		SamplingRate:        0.25,
+		RecordSamplingRatio: true,
+		SamplingRules:       []SamplingRule{{Pattern: "checkout", Sample: true}},
+	})
+
+	_, span := tracer.Start(context.Background(), "checkout")
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want the rule to sample checkout", len(spans))
+	}
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	if ratio, ok := attrs.Value(samplingRatioKey); ok {
+		t.Errorf("%s = %v, want none on a span a rule sampled", samplingRatioKey, ratio.Emit())
+	}
+}
+
+func TestAdaptiveSampler(t *testing.T) {
+	sampler := newAdaptiveSampler(AdaptiveSamplingConfig{TargetErrorRate: 0.5, Floor: 0.1, Window: time.Minute}, 1)
+	now := time.Unix(1700000000, 0)
+	sampler.now = func() time.Time { return now }
+	sampler.windowStart = now
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sampler))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	tracer := NewTracerFromProvider(tp, "test")
+	endSpans := func(total, failed int) {
+		for i := 0; i < total; i++ {
+			_, span := tracer.Start(context.Background(), "work")
+			if i < failed {
+				span.SetStatus(codes.Error, "failed")
+			}
+			span.End()
+		}
+	}
+
+	if got := sampler.currentRatio(); got != 0.1 {
+		t.Fatalf("initial ratio = %g, want the floor 0.1", got)
+	}
+
+	// Half the spans failing moves the smoothed error rate to 0.25, halfway to the target
+	endSpans(10, 5)
+	if got := sampler.currentRatio(); got != 0.1 {
+		t.Errorf("ratio = %g before the window elapsed, want 0.1", got)
+	}
+	now = now.Add(time.Minute)
+	if got := sampler.currentRatio(); math.Abs(got-0.55) > 1e-9 {
+		t.Errorf("ratio after failures = %g, want 0.55", got)
+	}
+
+	// Without failures the error rate halves every window and the ratio decays to the floor
+	want := []float64{0.325, 0.2125, 0.15625}
+	for _, w := range want {
+		endSpans(10, 0)
+		now = now.Add(time.Minute)
+		if got := sampler.currentRatio(); math.Abs(got-w) > 1e-9 {
+			t.Errorf("decayed ratio = %g, want %g", got, w)
+		}
+	}
+}
+
+func TestAdaptiveSamplerCeiling(t *testing.T) {
+	sampler := newAdaptiveSampler(AdaptiveSamplingConfig{Ceiling: 0.8, Window: time.Minute}, 0.2)
+	now := time.Unix(1700000000, 0)
+	sampler.now = func() time.Time { return now }
+	sampler.windowStart = now
+
+	// Every span failing is far above the default target, so the ratio stops at the ceiling
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sampler))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	for i := 0; i < 10; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), "work")
+		span.SetStatus(codes.Error, "failed")
+		span.End()
+	}
+	now = now.Add(time.Minute)
+	if got := sampler.currentRatio(); got != 0.8 {
+		t.Errorf("ratio = %g, want the ceiling 0.8", got)
+	}
+}
+
+// sequentialIDs generates trace IDs counting up from one, which any non-zero ratio samples
+type sequentialIDs struct {
+	next uint64
+}
+
+func (g *sequentialIDs) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.next++
+	var traceID trace.TraceID
+	binary.BigEndian.PutUint64(traceID[8:], g.next)
+	return traceID, g.NewSpanID(ctx, traceID)
+}
+
+func (g *sequentialIDs) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	g.next++
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], g.next)
+	return spanID
+}
+
+func TestAdaptiveSamplerRisesFromZeroFloor(t *testing.T) {
+	sampler := newAdaptiveSampler(AdaptiveSamplingConfig{Window: time.Minute}, 0)
+	now := time.Unix(1700000000, 0)
+	sampler.now = func() time.Time { return now }
+	sampler.windowStart = now
+
+	if got := sampler.currentRatio(); got != defaultAdaptiveFloor {
+		t.Fatalf("initial ratio = %g, want the default floor %g", got, defaultAdaptiveFloor)
+	}
+
+	// Only sampled spans reach the sampler, so failures must be sampled to raise the ratio
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(sampler),
+		sdktrace.WithIDGenerator(&sequentialIDs{}),
+	)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	for i := 0; i < 10; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), "work")
+		span.SetStatus(codes.Error, "failed")
+		span.End()
+	}
+	now = now.Add(time.Minute)
+	if got := sampler.currentRatio(); got != 1 {
+		t.Errorf("ratio = %g, want the ceiling 1 after sampled failures", got)
+	}
+}
+
+func TestRecordSamplingRatioWithAdaptiveSampling(t *testing.T) {
+	config := &TracingConfig{RecordSamplingRatio: true, AdaptiveSampling: &AdaptiveSamplingConfig{Floor: 0.5}}
+	adaptive := newAdaptiveSampler(*config.AdaptiveSampling, config.SamplingRate)
+	sampler, err := newSampler(config, adaptive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(recorder),
+		sdktrace.WithIDGenerator(&sequentialIDs{}),
+	)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	_, span := tp.Tracer("test").Start(context.Background(), "work")
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want the root sampled", len(spans))
+	}
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	if ratio, _ := attrs.Value(samplingRatioKey); ratio.AsFloat64() != 0.5 {
+		t.Errorf("%s = %v, want the adaptive ratio 0.5", samplingRatioKey, ratio.Emit())
+	}
+}
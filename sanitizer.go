@@ -0,0 +1,172 @@
+package observability
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// AttributeSanitizer rewrites or drops attributes before they reach metrics or spans
+type AttributeSanitizer interface {
+	// Sanitize returns the attribute to export, or false to drop it
+	Sanitize(kv attribute.KeyValue) (attribute.KeyValue, bool)
+}
+
+// emailPattern matches email addresses
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// PatternSanitizer masks the parts of string attribute values matching any of its patterns
+type PatternSanitizer struct {
+	Patterns []*regexp.Regexp
+	// Replacement substitutes each match unless Hash is set
+	Replacement string
+	// Hash substitutes each match with its SHA-256 digest so equal values stay correlatable
+	Hash bool
+}
+
+// NewEmailSanitizer returns a sanitizer that masks email addresses
+func NewEmailSanitizer() *PatternSanitizer {
+	return &PatternSanitizer{
+		Patterns:    []*regexp.Regexp{emailPattern},
+		Replacement: "[REDACTED]",
+	}
+}
+
+// Sanitize masks matches in string and string slice values; other attributes are returned unchanged
+func (s *PatternSanitizer) Sanitize(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		return kv.Key.String(s.mask(kv.Value.AsString())), true
+	case attribute.STRINGSLICE:
+		values := kv.Value.AsStringSlice()
+		for i, v := range values {
+			values[i] = s.mask(v)
+		}
+		return kv.Key.StringSlice(values), true
+	default:
+		return kv, true
+	}
+}
+
+// mask replaces every match of the patterns in v
+func (s *PatternSanitizer) mask(v string) string {
+	for _, pattern := range s.Patterns {
+		v = pattern.ReplaceAllStringFunc(v, func(match string) string {
+			if s.Hash {
+				sum := sha256.Sum256([]byte(match))
+				return hex.EncodeToString(sum[:])
+			}
+			return s.Replacement
+		})
+	}
+	return v
+}
+
+// truncatingSanitizer shortens string values longer than max bytes, marking them with a trailing "..."
+type truncatingSanitizer struct {
+	max int
+}
+
+// Sanitize truncates long string and string slice values; other attributes are returned unchanged
+func (s truncatingSanitizer) Sanitize(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		return kv.Key.String(s.truncate(kv.Value.AsString())), true
+	case attribute.STRINGSLICE:
+		values := kv.Value.AsStringSlice()
+		for i, v := range values {
+			values[i] = s.truncate(v)
+		}
+		return kv.Key.StringSlice(values), true
+	default:
+		return kv, true
+	}
+}
+
+// truncate cuts v to at most max bytes without splitting a UTF-8 sequence
+func (s truncatingSanitizer) truncate(v string) string {
+	if len(v) <= s.max {
+		return v
+	}
+	cut := s.max
+	for cut > 0 && !utf8.RuneStart(v[cut]) {
+		cut--
+	}
+	return v[:cut] + "..."
+}
+
+// sanitizerChain applies each sanitizer in turn, stopping when one drops the attribute
+type sanitizerChain []AttributeSanitizer
+
+// Sanitize passes the attribute through every sanitizer in the chain
+func (c sanitizerChain) Sanitize(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	for _, sanitizer := range c {
+		var ok bool
+		if kv, ok = sanitizer.Sanitize(kv); !ok {
+			return kv, false
+		}
+	}
+	return kv, true
+}
+
+// sanitizeAttributes applies the sanitizer to attrs, returning them unchanged when it is nil
+func sanitizeAttributes(sanitizer AttributeSanitizer, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if sanitizer == nil || len(attrs) == 0 {
+		return attrs
+	}
+	sanitized := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		if kv, ok := sanitizer.Sanitize(kv); ok {
+			sanitized = append(sanitized, kv)
+		}
+	}
+	return sanitized
+}
+
+// sanitizingProcessor sanitizes span and event attributes before handing spans to the next processor
+type sanitizingProcessor struct {
+	sdktrace.SpanProcessor
+	sanitizer AttributeSanitizer
+}
+
+// newSanitizingProcessor wraps next so it only sees sanitized attributes
+func newSanitizingProcessor(next sdktrace.SpanProcessor, sanitizer AttributeSanitizer) sdktrace.SpanProcessor {
+	return &sanitizingProcessor{SpanProcessor: next, sanitizer: sanitizer}
+}
+
+// OnEnd passes a sanitized view of the span to the next processor
+func (p *sanitizingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	events := s.Events()
+	sanitizedEvents := make([]sdktrace.Event, len(events))
+	for i, event := range events {
+		event.Attributes = sanitizeAttributes(p.sanitizer, event.Attributes)
+		sanitizedEvents[i] = event
+	}
+
+	p.SpanProcessor.OnEnd(&sanitizedSpan{
+		ReadOnlySpan: s,
+		attributes:   sanitizeAttributes(p.sanitizer, s.Attributes()),
+		events:       sanitizedEvents,
+	})
+}
+
+// sanitizedSpan overrides the attributes and events of an ended span
+type sanitizedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+}
+
+// Attributes returns the sanitized span attributes
+func (s *sanitizedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+// Events returns the span events with sanitized attributes
+func (s *sanitizedSpan) Events() []sdktrace.Event {
+	return s.events
+}
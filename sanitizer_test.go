@@ -0,0 +1,148 @@
+package observability
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSanitizerMasksMetricAttributes(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{Sanitizer: NewEmailSanitizer()})
+
+	err := m.IncrementCounter(context.Background(), "signups", 1, attribute.String("user", "contact: alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := collect(t, reader)["signups"].Data.(metricdata.Sum[int64])
+	if v, _ := sum.DataPoints[0].Attributes.Value("user"); v.AsString() != "contact: [REDACTED]" {
+		t.Errorf("user = %q, want the email masked", v.AsString())
+	}
+}
+
+func TestSanitizerDropsAttributesOfInstrumentsUsedDirectly(t *testing.T) {
+	ctx := context.Background()
+	m, reader := newTestMetrics(t, MetricsConfig{Sanitizer: NewEmailSanitizer()})
+
+	counter, err := m.meter.Int64Counter("logins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("user", "alice@example.com"), attribute.String("method", "sso")))
+
+	processor, err := newSpanMetricsProcessor(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := tracetest.SpanStub{Name: "notify bob@example.org"}.Snapshot()
+	processor.OnEnd(span)
+
+	metrics := collect(t, reader)
+	attrs := metrics["logins"].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+	if _, ok := attrs.Value("user"); ok || attrs.Len() != 1 {
+		t.Errorf("logins attributes = %v, want only the attribute without an email", attrs.ToSlice())
+	}
+	point := metrics[spanDurationMetric].Data.(metricdata.Histogram[float64]).DataPoints[0]
+	if _, ok := point.Attributes.Value("span.name"); ok {
+		t.Errorf("span duration attributes = %v, want the span name with an email dropped", point.Attributes.ToSlice())
+	}
+}
+
+func TestSanitizerMasksSpanAttributes(t *testing.T) {
+	ctx := context.Background()
+	spans := tracetest.NewInMemoryExporter()
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:               true,
+		SamplingRate:          1,
+		DisableGlobalProvider: true,
+		SpanExporter:          spans,
+		Sanitizer:             NewEmailSanitizer(),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = shutdown(ctx) })
+
+	_, span := tracer.Start(ctx, "signup", trace.WithAttributes(attribute.String("user", "alice@example.com")))
+	span.AddEvent("welcome sent", trace.WithAttributes(attribute.StringSlice("to", []string{"bob@example.org", "ops"})))
+	span.End()
+	if err := tracer.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := spans.GetSpans()[0]
+	if got := stub.Attributes[0]; got != attribute.String("user", "[REDACTED]") {
+		t.Errorf("span attribute = %v, want the email masked", got)
+	}
+	to := stub.Events[0].Attributes[0].Value.AsStringSlice()
+	if len(to) != 2 || to[0] != "[REDACTED]" || to[1] != "ops" {
+		t.Errorf("event attribute = %v, want only the email masked", to)
+	}
+}
+
+func TestPatternSanitizerHash(t *testing.T) {
+	sanitizer := &PatternSanitizer{Patterns: []*regexp.Regexp{emailPattern}, Hash: true}
+	sum := sha256.Sum256([]byte("alice@example.com"))
+
+	got, ok := sanitizer.Sanitize(attribute.String("user", "alice@example.com"))
+	if !ok || got.Value.AsString() != hex.EncodeToString(sum[:]) {
+		t.Errorf("Sanitize() = %v, %v, want the SHA-256 of the email", got, ok)
+	}
+	if got, _ := sanitizer.Sanitize(attribute.Int("age", 42)); got != attribute.Int("age", 42) {
+		t.Errorf("Sanitize() = %v, want non-string attributes unchanged", got)
+	}
+}
+
+func TestMaxAttributeValueLength(t *testing.T) {
+	ctx := context.Background()
+	spans := tracetest.NewInMemoryExporter()
+	tracer, shutdown, err := setupTracing(ctx, &TracingConfig{
+		Enabled:                 true,
+		SamplingRate:            1,
+		DisableGlobalProvider:   true,
+		SpanExporter:            spans,
+		MaxAttributeValueLength: 8,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = shutdown(ctx) })
+
+	_, span := tracer.Start(ctx, "upload", trace.WithAttributes(
+		attribute.String("payload", strings.Repeat("x", 1024)),
+		attribute.String("short", "ok"),
+		attribute.Int("size", 1024),
+	))
+	span.End()
+	if err := tracer.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := attribute.NewSet(spans.GetSpans()[0].Attributes...)
+	want := map[attribute.Key]string{"payload": "xxxxxxxx...", "short": "ok", "size": "1024"}
+	for key, value := range want {
+		if v, _ := attrs.Value(key); v.Emit() != value {
+			t.Errorf("%s = %q, want %q", key, v.Emit(), value)
+		}
+	}
+}
+
+func TestTruncateKeepsRunesWhole(t *testing.T) {
+	sanitizer := truncatingSanitizer{max: 4}
+	// "é" is two bytes, so cutting at four bytes would split the second one
+	if got := sanitizer.truncate("aéé"); got != "aé..." {
+		t.Errorf("truncate() = %q, want %q", got, "aé...")
+	}
+	if got := sanitizer.truncate("abcd"); got != "abcd" {
+		t.Errorf("truncate() = %q, want values at the limit unchanged", got)
+	}
+}
@@ -0,0 +1,61 @@
+package observability
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flushSignalTimeout bounds a flush triggered by a signal
+const flushSignalTimeout = 5 * time.Second
+
+// watchFlushSignal flushes metrics every time the process receives a flush signal
+// until the returned func is called. It does nothing on platforms without one.
+func watchFlushSignal(metrics *Metrics, logger *Logger) func() {
+	if len(flushSignals) == 0 {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, flushSignals...)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-signals:
+				flushOnSignal(metrics, logger)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// flushOnSignal exports all pending metrics in response to a flush signal
+func flushOnSignal(metrics *Metrics, logger *Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), flushSignalTimeout)
+	defer cancel()
+
+	if err := metrics.ForceFlush(ctx); err != nil {
+		logger.Error(ctx, "Error flushing metrics on signal", zap.Error(err))
+		return
+	}
+	logger.Info(ctx, "Flushed metrics on signal")
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package observability
+
+import "os"
+
+// flushSignals is empty since SIGUSR1 is unavailable on this platform
+var flushSignals []os.Signal
@@ -0,0 +1,59 @@
+package observability
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFlushOnSignal(t *testing.T) {
+	tests := []struct {
+		name    string
+		down    bool
+		message string
+	}{
+		{"flushed", false, "Flushed metrics on signal"},
+		{"collector down", true, "Error flushing metrics on signal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			exporter := &flakyMetricExporter{down: tt.down}
+			metrics, err := NewMetrics(ctx, MetricsConfig{
+				Enabled:               true,
+				DisableGlobalProvider: true,
+				MetricExporter:        exporter,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = metrics.Shutdown(ctx) })
+			logger, buf := newTestLogger(t, nil)
+
+			if err := metrics.IncrementCounter(ctx, "jobs", 3); err != nil {
+				t.Fatal(err)
+			}
+			flushOnSignal(metrics, logger)
+
+			if total, ok := exporter.counter("jobs"); ok == tt.down || (ok && total != 3) {
+				t.Errorf("exported jobs = %d, %v; want exported only while the collector is up", total, ok)
+			}
+			entries := logEntries(t, buf)
+			if len(entries) != 1 || entries[0]["message"] != tt.message {
+				t.Errorf("entries = %v, want %q", entries, tt.message)
+			}
+		})
+	}
+}
+
+func TestWatchFlushSignalStops(t *testing.T) {
+	metrics, err := NewMetrics(context.Background(), MetricsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, _ := newTestLogger(t, nil)
+
+	stop := watchFlushSignal(metrics, logger)
+	// Stopping waits for the watcher to exit and is safe to repeat
+	stop()
+	stop()
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package observability
+
+import (
+	"os"
+	"syscall"
+)
+
+// flushSignals trigger an immediate metrics export
+var flushSignals = []os.Signal{syscall.SIGUSR1}
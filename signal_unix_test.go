@@ -0,0 +1,42 @@
+//go:build unix
+
+package observability
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchFlushSignalFlushesOnSIGUSR1(t *testing.T) {
+	ctx := context.Background()
+	exporter := &flakyMetricExporter{}
+	metrics, err := NewMetrics(ctx, MetricsConfig{
+		Enabled:               true,
+		DisableGlobalProvider: true,
+		MetricExporter:        exporter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = metrics.Shutdown(ctx) })
+	logger, _ := newTestLogger(t, nil)
+
+	stop := watchFlushSignal(metrics, logger)
+	defer stop()
+
+	if err := metrics.IncrementCounter(ctx, "jobs", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if total, ok := exporter.counter("jobs"); ok && total == 1 {
+			return
+		}
+	}
+	t.Error("metrics were not flushed after SIGUSR1")
+}
@@ -0,0 +1,128 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// ErrSnapshotDisabled is returned by Snapshot unless MetricsConfig.EnableSnapshot is set
+var ErrSnapshotDisabled = errors.New("metrics snapshots are not enabled")
+
+// snapshotPercentiles are the percentiles reported for histograms in snapshots
+var snapshotPercentiles = []float64{50, 90, 99}
+
+// Snapshot collects the current value of every metric, keyed by name, for debug and
+// admin endpoints. Each entry holds the metric type and its points with their
+// attributes; counters also report the total across points and histograms report
+// count, sum, min, max and approximate percentiles instead of a value. The result
+// encodes directly as JSON.
+func (m *Metrics) Snapshot(ctx context.Context) (map[string]interface{}, error) {
+	if m.snapshot == nil {
+		return nil, ErrSnapshotDisabled
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := m.snapshot.Collect(ctx, &rm); err != nil {
+		return nil, fmt.Errorf("failed to collect metrics: %w", err)
+	}
+
+	snapshot := make(map[string]interface{})
+	for _, scope := range rm.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			if entry := snapshotEntry(metric.Data); entry != nil {
+				snapshot[metric.Name] = entry
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// snapshotEntry summarizes collected metric data, or returns nil for unsupported types
+func snapshotEntry(data metricdata.Aggregation) map[string]interface{} {
+	switch data := data.(type) {
+	case metricdata.Sum[int64]:
+		return sumEntry(data.DataPoints)
+	case metricdata.Sum[float64]:
+		return sumEntry(data.DataPoints)
+	case metricdata.Gauge[int64]:
+		return gaugeEntry(data.DataPoints)
+	case metricdata.Gauge[float64]:
+		return gaugeEntry(data.DataPoints)
+	case metricdata.Histogram[float64]:
+		points := make([]map[string]interface{}, len(data.DataPoints))
+		for i, dp := range data.DataPoints {
+			point := histogramPoint(dp.Attributes, dp.Count, dp.Sum, dp.Min, dp.Max)
+			percentiles := ComputePercentiles(dp, snapshotPercentiles...)
+			for _, p := range snapshotPercentiles {
+				if v, ok := percentiles[p]; ok {
+					point[fmt.Sprintf("p%g", p)] = v
+				}
+			}
+			points[i] = point
+		}
+		return map[string]interface{}{"type": "histogram", "points": points}
+	case metricdata.Histogram[int64]:
+		points := make([]map[string]interface{}, len(data.DataPoints))
+		for i, dp := range data.DataPoints {
+			points[i] = histogramPoint(dp.Attributes, dp.Count, dp.Sum, dp.Min, dp.Max)
+		}
+		return map[string]interface{}{"type": "histogram", "points": points}
+	case metricdata.ExponentialHistogram[float64]:
+		points := make([]map[string]interface{}, len(data.DataPoints))
+		for i, dp := range data.DataPoints {
+			points[i] = histogramPoint(dp.Attributes, dp.Count, dp.Sum, dp.Min, dp.Max)
+		}
+		return map[string]interface{}{"type": "histogram", "points": points}
+	default:
+		return nil
+	}
+}
+
+// sumEntry summarizes the points of a counter or up/down counter
+func sumEntry[N int64 | float64](dps []metricdata.DataPoint[N]) map[string]interface{} {
+	var total N
+	points := make([]map[string]interface{}, len(dps))
+	for i, dp := range dps {
+		total += dp.Value
+		points[i] = map[string]interface{}{"attributes": snapshotAttributes(dp.Attributes), "value": dp.Value}
+	}
+	return map[string]interface{}{"type": "sum", "total": total, "points": points}
+}
+
+// gaugeEntry summarizes the points of a gauge
+func gaugeEntry[N int64 | float64](dps []metricdata.DataPoint[N]) map[string]interface{} {
+	points := make([]map[string]interface{}, len(dps))
+	for i, dp := range dps {
+		points[i] = map[string]interface{}{"attributes": snapshotAttributes(dp.Attributes), "value": dp.Value}
+	}
+	return map[string]interface{}{"type": "gauge", "points": points}
+}
+
+// histogramPoint summarizes a histogram point; min and max are omitted if not recorded
+func histogramPoint[N int64 | float64](attrs attribute.Set, count uint64, sum N, min, max metricdata.Extrema[N]) map[string]interface{} {
+	point := map[string]interface{}{
+		"attributes": snapshotAttributes(attrs),
+		"count":      count,
+		"sum":        sum,
+	}
+	if v, ok := min.Value(); ok {
+		point["min"] = v
+	}
+	if v, ok := max.Value(); ok {
+		point["max"] = v
+	}
+	return point
+}
+
+// snapshotAttributes converts an attribute set to a map from key to value
+func snapshotAttributes(attrs attribute.Set) map[string]interface{} {
+	values := make(map[string]interface{}, attrs.Len())
+	for _, kv := range attrs.ToSlice() {
+		values[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return values
+}
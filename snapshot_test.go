@@ -0,0 +1,95 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// newSnapshotMetrics creates metrics with snapshots enabled, exporting to a fake exporter
+func newSnapshotMetrics(t *testing.T) *Metrics {
+	t.Helper()
+	m, err := NewMetrics(context.Background(), MetricsConfig{
+		Enabled:               true,
+		DisableGlobalProvider: true,
+		MetricExporter:        &flakyMetricExporter{},
+		EnableSnapshot:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Shutdown(context.Background()) })
+	return m
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	m := newSnapshotMetrics(t)
+
+	if err := m.IncrementCounter(ctx, "requests", 3, attribute.String("route", "/orders")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.IncrementCounter(ctx, "requests", 2, attribute.String("route", "/users")); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{0.1, 0.2, 0.4} {
+		if err := m.RecordHistogram(ctx, "latency", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.CreateGauge("temperature", "", func() float64 { return 21.5 }); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := m.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	requests, ok := snapshot["requests"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("snapshot has no requests entry: %v", snapshot)
+	}
+	if requests["type"] != "sum" || requests["total"] != int64(5) {
+		t.Errorf("requests = %v, want a sum totalling 5", requests)
+	}
+	points := requests["points"].([]map[string]interface{})
+	values := map[interface{}]interface{}{}
+	for _, point := range points {
+		values[point["attributes"].(map[string]interface{})["route"]] = point["value"]
+	}
+	if values["/orders"] != int64(3) || values["/users"] != int64(2) {
+		t.Errorf("requests points = %v, want 3 for /orders and 2 for /users", points)
+	}
+
+	latency := snapshot["latency"].(map[string]interface{})
+	point := latency["points"].([]map[string]interface{})[0]
+	if latency["type"] != "histogram" || point["count"] != uint64(3) || point["min"] != 0.1 || point["max"] != 0.4 {
+		t.Errorf("latency = %v, want a histogram of 3 values from 0.1 to 0.4", latency)
+	}
+	for _, key := range []string{"p50", "p90", "p99"} {
+		if _, ok := point[key]; !ok {
+			t.Errorf("latency point has no %s: %v", key, point)
+		}
+	}
+
+	temperature := snapshot["temperature"].(map[string]interface{})
+	if temperature["type"] != "gauge" || temperature["points"].([]map[string]interface{})[0]["value"] != 21.5 {
+		t.Errorf("temperature = %v, want a gauge of 21.5", temperature)
+	}
+
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Errorf("snapshot does not encode as JSON: %v", err)
+	}
+}
+
+func TestSnapshotDisabled(t *testing.T) {
+	m, _ := newTestMetrics(t, MetricsConfig{})
+
+	if _, err := m.Snapshot(context.Background()); !errors.Is(err, ErrSnapshotDisabled) {
+		t.Errorf("Snapshot() = %v, want ErrSnapshotDisabled", err)
+	}
+}
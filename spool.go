@@ -0,0 +1,478 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// spoolFileExt is the extension of spooled batch files
+	spoolFileExt = ".json"
+	// maxSpoolFiles bounds the batches kept on disk; batches failing beyond it are dropped
+	maxSpoolFiles = 1000
+	// maxReplayBatches bounds the spooled batches replayed after each successful export,
+	// so draining a long backlog doesn't hold up the export that triggered it
+	maxReplayBatches = 4
+)
+
+// errCorruptSpoolFile marks spooled batches that can't be decoded
+var errCorruptSpoolFile = errors.New("corrupt spool file")
+
+// spool is a directory of JSON batches that failed to export, replayed oldest first
+type spool struct {
+	dir string
+
+	// mu guards seq and the file count check so concurrent writes stay under the cap
+	mu  sync.Mutex
+	seq uint64
+	// replaying is held by the one export replaying batches, so no batch is replayed twice
+	replaying sync.Mutex
+}
+
+// newSpool creates a spool in dir, creating the directory if needed
+func newSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return &spool{dir: dir}, nil
+}
+
+// write encodes batch to a new file in the spool directory
+func (s *spool) write(batch interface{}) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode spooled batch: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	if len(files) >= maxSpoolFiles {
+		return fmt.Errorf("spool directory %s is full, dropping batch", s.dir)
+	}
+
+	// Name files by time and sequence so they sort in the order they were written
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq%1000000, spoolFileExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	// Rename so a crash mid-write never leaves a truncated batch to replay
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+// replay passes up to maxReplayBatches spooled batches, oldest first, to export and
+// removes those it accepts. It stops at the first export failure and does nothing if
+// another export is already replaying. Batches export reports as corrupt with
+// errCorruptSpoolFile are dropped, since they would block every later one.
+func (s *spool) replay(export func(data []byte) error) {
+	if !s.replaying.TryLock() {
+		return
+	}
+	defer s.replaying.Unlock()
+
+	files, err := s.files()
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	if len(files) > maxReplayBatches {
+		files = files[:maxReplayBatches]
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			err = export(data)
+		}
+		if errors.Is(err, errCorruptSpoolFile) {
+			otel.Handle(fmt.Errorf("dropping spool file %s: %w", file, err))
+		} else if err != nil {
+			return
+		}
+		if err := os.Remove(file); err != nil {
+			otel.Handle(fmt.Errorf("failed to remove replayed spool file: %w", err))
+			return
+		}
+	}
+}
+
+// files lists the spooled batch files, oldest first
+func (s *spool) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+spoolFileExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// FileSpoolExporter wraps a span exporter so batches that fail to export are written
+// to a directory instead of being lost. A few spooled batches are replayed, oldest
+// first, after each successful export until the spool is drained.
+type FileSpoolExporter struct {
+	sdktrace.SpanExporter
+	spool *spool
+}
+
+// NewFileSpoolExporter creates a FileSpoolExporter spooling to dir, creating it if needed
+func NewFileSpoolExporter(exporter sdktrace.SpanExporter, dir string) (*FileSpoolExporter, error) {
+	spool, err := newSpool(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSpoolExporter{SpanExporter: exporter, spool: spool}, nil
+}
+
+// ExportSpans exports spans, spooling them if the export fails. The failure is only
+// returned if the spans could not be spooled either.
+func (e *FileSpoolExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if err := e.SpanExporter.ExportSpans(ctx, spans); err != nil {
+		records := make([]spoolSpan, len(spans))
+		for i, span := range spans {
+			records[i] = newSpoolSpan(span)
+		}
+		if spoolErr := e.spool.write(records); spoolErr != nil {
+			return errors.Join(err, spoolErr)
+		}
+		return nil
+	}
+
+	e.spool.replay(func(data []byte) error {
+		spans, err := decodeSpoolSpans(data)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errCorruptSpoolFile, err)
+		}
+		return e.SpanExporter.ExportSpans(ctx, spans)
+	})
+	return nil
+}
+
+// decodeSpoolSpans decodes a spooled batch back into spans
+func decodeSpoolSpans(data []byte) ([]sdktrace.ReadOnlySpan, error) {
+	var records []spoolSpan
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	spans := make([]sdktrace.ReadOnlySpan, len(records))
+	for i, record := range records {
+		var err error
+		if spans[i], err = record.snapshot(); err != nil {
+			return nil, err
+		}
+	}
+	return spans, nil
+}
+
+// spoolSpan is the on-disk form of a span
+type spoolSpan struct {
+	Name              string           `json:"name"`
+	SpanContext       spoolSpanContext `json:"span_context"`
+	Parent            spoolSpanContext `json:"parent"`
+	Kind              trace.SpanKind   `json:"kind"`
+	StartTime         time.Time        `json:"start_time"`
+	EndTime           time.Time        `json:"end_time"`
+	Attributes        []spoolAttribute `json:"attributes,omitempty"`
+	Events            []spoolEvent     `json:"events,omitempty"`
+	Links             []spoolLink      `json:"links,omitempty"`
+	StatusCode        codes.Code       `json:"status_code"`
+	StatusDescription string           `json:"status_description,omitempty"`
+	DroppedAttributes int              `json:"dropped_attributes,omitempty"`
+	DroppedEvents     int              `json:"dropped_events,omitempty"`
+	DroppedLinks      int              `json:"dropped_links,omitempty"`
+	ChildSpanCount    int              `json:"child_span_count,omitempty"`
+	Resource          []spoolAttribute `json:"resource,omitempty"`
+	ResourceSchemaURL string           `json:"resource_schema_url,omitempty"`
+	Scope             spoolScope       `json:"scope"`
+}
+
+// spoolSpanContext is the on-disk form of a span context
+type spoolSpanContext struct {
+	TraceID    string `json:"trace_id,omitempty"`
+	SpanID     string `json:"span_id,omitempty"`
+	TraceFlags byte   `json:"trace_flags,omitempty"`
+	TraceState string `json:"trace_state,omitempty"`
+	Remote     bool   `json:"remote,omitempty"`
+}
+
+// spoolEvent is the on-disk form of a span event
+type spoolEvent struct {
+	Name              string           `json:"name"`
+	Time              time.Time        `json:"time"`
+	Attributes        []spoolAttribute `json:"attributes,omitempty"`
+	DroppedAttributes int              `json:"dropped_attributes,omitempty"`
+}
+
+// spoolLink is the on-disk form of a span link
+type spoolLink struct {
+	SpanContext       spoolSpanContext `json:"span_context"`
+	Attributes        []spoolAttribute `json:"attributes,omitempty"`
+	DroppedAttributes int              `json:"dropped_attributes,omitempty"`
+}
+
+// spoolScope is the on-disk form of an instrumentation scope
+type spoolScope struct {
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	SchemaURL string `json:"schema_url,omitempty"`
+}
+
+// spoolAttribute is the on-disk form of an attribute, keeping its type so it decodes
+// back to the same value
+type spoolAttribute struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// newSpoolSpan converts a span to its on-disk form
+func newSpoolSpan(s sdktrace.ReadOnlySpan) spoolSpan {
+	record := spoolSpan{
+		Name:              s.Name(),
+		SpanContext:       newSpoolSpanContext(s.SpanContext()),
+		Parent:            newSpoolSpanContext(s.Parent()),
+		Kind:              s.SpanKind(),
+		StartTime:         s.StartTime(),
+		EndTime:           s.EndTime(),
+		Attributes:        newSpoolAttributes(s.Attributes()),
+		StatusCode:        s.Status().Code,
+		StatusDescription: s.Status().Description,
+		DroppedAttributes: s.DroppedAttributes(),
+		DroppedEvents:     s.DroppedEvents(),
+		DroppedLinks:      s.DroppedLinks(),
+		ChildSpanCount:    s.ChildSpanCount(),
+		Scope: spoolScope{
+			Name:      s.InstrumentationScope().Name,
+			Version:   s.InstrumentationScope().Version,
+			SchemaURL: s.InstrumentationScope().SchemaURL,
+		},
+	}
+	if res := s.Resource(); res != nil {
+		record.Resource = newSpoolAttributes(res.Attributes())
+		record.ResourceSchemaURL = res.SchemaURL()
+	}
+	for _, event := range s.Events() {
+		record.Events = append(record.Events, spoolEvent{
+			Name:              event.Name,
+			Time:              event.Time,
+			Attributes:        newSpoolAttributes(event.Attributes),
+			DroppedAttributes: event.DroppedAttributeCount,
+		})
+	}
+	for _, link := range s.Links() {
+		record.Links = append(record.Links, spoolLink{
+			SpanContext:       newSpoolSpanContext(link.SpanContext),
+			Attributes:        newSpoolAttributes(link.Attributes),
+			DroppedAttributes: link.DroppedAttributeCount,
+		})
+	}
+	return record
+}
+
+// snapshot converts the on-disk form back to a span
+func (r spoolSpan) snapshot() (sdktrace.ReadOnlySpan, error) {
+	spanContext, err := r.SpanContext.spanContext()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := r.Parent.spanContext()
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := spoolAttributes(r.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	resourceAttrs, err := spoolAttributes(r.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := tracetest.SpanStub{
+		Name:                 r.Name,
+		SpanContext:          spanContext,
+		Parent:               parent,
+		SpanKind:             r.Kind,
+		StartTime:            r.StartTime,
+		EndTime:              r.EndTime,
+		Attributes:           attrs,
+		Status:               sdktrace.Status{Code: r.StatusCode, Description: r.StatusDescription},
+		DroppedAttributes:    r.DroppedAttributes,
+		DroppedEvents:        r.DroppedEvents,
+		DroppedLinks:         r.DroppedLinks,
+		ChildSpanCount:       r.ChildSpanCount,
+		Resource:             resource.NewWithAttributes(r.ResourceSchemaURL, resourceAttrs...),
+		InstrumentationScope: instrumentation.Scope{Name: r.Scope.Name, Version: r.Scope.Version, SchemaURL: r.Scope.SchemaURL},
+	}
+	for _, event := range r.Events {
+		eventAttrs, err := spoolAttributes(event.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		stub.Events = append(stub.Events, sdktrace.Event{
+			Name:                  event.Name,
+			Time:                  event.Time,
+			Attributes:            eventAttrs,
+			DroppedAttributeCount: event.DroppedAttributes,
+		})
+	}
+	for _, link := range r.Links {
+		linkContext, err := link.SpanContext.spanContext()
+		if err != nil {
+			return nil, err
+		}
+		linkAttrs, err := spoolAttributes(link.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		stub.Links = append(stub.Links, sdktrace.Link{
+			SpanContext:           linkContext,
+			Attributes:            linkAttrs,
+			DroppedAttributeCount: link.DroppedAttributes,
+		})
+	}
+	return stub.Snapshot(), nil
+}
+
+// newSpoolSpanContext converts a span context to its on-disk form; invalid ones are left empty
+func newSpoolSpanContext(sc trace.SpanContext) spoolSpanContext {
+	if !sc.IsValid() {
+		return spoolSpanContext{}
+	}
+	return spoolSpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		TraceFlags: byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+		Remote:     sc.IsRemote(),
+	}
+}
+
+// spanContext converts the on-disk form back to a span context
+func (c spoolSpanContext) spanContext() (trace.SpanContext, error) {
+	if c.TraceID == "" {
+		return trace.SpanContext{}, nil
+	}
+	traceID, err := trace.TraceIDFromHex(c.TraceID)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	spanID, err := trace.SpanIDFromHex(c.SpanID)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	state, err := trace.ParseTraceState(c.TraceState)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(c.TraceFlags),
+		TraceState: state,
+		Remote:     c.Remote,
+	}), nil
+}
+
+// newSpoolAttributes converts attributes to their on-disk form
+func newSpoolAttributes(attrs []attribute.KeyValue) []spoolAttribute {
+	if len(attrs) == 0 {
+		return nil
+	}
+	records := make([]spoolAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		value, err := json.Marshal(attr.Value.AsInterface())
+		if err != nil {
+			// Non-finite floats can't be encoded as JSON numbers
+			value, _ = json.Marshal(attr.Value.Emit())
+			records = append(records, spoolAttribute{Key: string(attr.Key), Type: attribute.STRING.String(), Value: value})
+			continue
+		}
+		records = append(records, spoolAttribute{Key: string(attr.Key), Type: attr.Value.Type().String(), Value: value})
+	}
+	return records
+}
+
+// spoolAttributes converts attributes back from their on-disk form
+func spoolAttributes(records []spoolAttribute) ([]attribute.KeyValue, error) {
+	attrs := make([]attribute.KeyValue, 0, len(records))
+	for _, record := range records {
+		attr, err := record.keyValue()
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", record.Key, err)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// keyValue decodes the attribute according to its recorded type
+func (a spoolAttribute) keyValue() (attribute.KeyValue, error) {
+	key := attribute.Key(a.Key)
+	switch a.Type {
+	case attribute.BOOL.String():
+		var v bool
+		err := json.Unmarshal(a.Value, &v)
+		return key.Bool(v), err
+	case attribute.INT64.String():
+		var v int64
+		err := json.Unmarshal(a.Value, &v)
+		return key.Int64(v), err
+	case attribute.FLOAT64.String():
+		var v float64
+		err := json.Unmarshal(a.Value, &v)
+		return key.Float64(v), err
+	case attribute.STRING.String():
+		var v string
+		err := json.Unmarshal(a.Value, &v)
+		return key.String(v), err
+	case attribute.BOOLSLICE.String():
+		var v []bool
+		err := json.Unmarshal(a.Value, &v)
+		return key.BoolSlice(v), err
+	case attribute.INT64SLICE.String():
+		var v []int64
+		err := json.Unmarshal(a.Value, &v)
+		return key.Int64Slice(v), err
+	case attribute.FLOAT64SLICE.String():
+		var v []float64
+		err := json.Unmarshal(a.Value, &v)
+		return key.Float64Slice(v), err
+	case attribute.STRINGSLICE.String():
+		var v []string
+		err := json.Unmarshal(a.Value, &v)
+		return key.StringSlice(v), err
+	default:
+		return attribute.KeyValue{}, fmt.Errorf("unsupported type %s", a.Type)
+	}
+}
+
+// spoolDir returns the spool directory for the exporter at index i of n, giving each
+// exporter its own subdirectory when there are several so they don't replay each other's batches
+func spoolDir(dir string, i, n int) string {
+	if n == 1 {
+		return dir
+	}
+	return filepath.Join(dir, strconv.Itoa(i))
+}
@@ -0,0 +1,419 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// FileSpoolMetricExporter wraps a metric exporter so collections that fail to export are
+// written to a directory instead of being lost. A few spooled collections are replayed,
+// oldest first, after each successful export until the spool is drained, so they can
+// arrive after newer ones. Exemplars are not spooled.
+type FileSpoolMetricExporter struct {
+	sdkmetric.Exporter
+	spool *spool
+}
+
+// NewFileSpoolMetricExporter creates a FileSpoolMetricExporter spooling to dir, creating it if needed
+func NewFileSpoolMetricExporter(exporter sdkmetric.Exporter, dir string) (*FileSpoolMetricExporter, error) {
+	spool, err := newSpool(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSpoolMetricExporter{Exporter: exporter, spool: spool}, nil
+}
+
+// Export exports rm, spooling it if the export fails. The failure is only returned if
+// rm could not be spooled either.
+func (e *FileSpoolMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if err := e.Exporter.Export(ctx, rm); err != nil {
+		// The reader reuses rm once Export returns, so encode it now
+		record, spoolErr := newSpoolResourceMetrics(rm)
+		if spoolErr == nil {
+			spoolErr = e.spool.write(record)
+		}
+		if spoolErr != nil {
+			return errors.Join(err, spoolErr)
+		}
+		return nil
+	}
+
+	e.spool.replay(func(data []byte) error {
+		var record spoolResourceMetrics
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("%w: %w", errCorruptSpoolFile, err)
+		}
+		rm, err := record.resourceMetrics()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errCorruptSpoolFile, err)
+		}
+		return e.Exporter.Export(ctx, rm)
+	})
+	return nil
+}
+
+// spoolResourceMetrics is the on-disk form of a metrics collection
+type spoolResourceMetrics struct {
+	Resource          []spoolAttribute    `json:"resource,omitempty"`
+	ResourceSchemaURL string              `json:"resource_schema_url,omitempty"`
+	Scopes            []spoolScopeMetrics `json:"scopes"`
+}
+
+// spoolScopeMetrics is the on-disk form of the metrics of an instrumentation scope
+type spoolScopeMetrics struct {
+	Scope   spoolScope    `json:"scope"`
+	Metrics []spoolMetric `json:"metrics"`
+}
+
+// spoolMetric is the on-disk form of a metric. Points holds the data points in the form
+// matching Kind, with values of the Number type.
+type spoolMetric struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Unit        string          `json:"unit,omitempty"`
+	Kind        string          `json:"kind"`
+	Number      string          `json:"number"`
+	Temporality string          `json:"temporality,omitempty"`
+	Monotonic   bool            `json:"monotonic,omitempty"`
+	Points      json.RawMessage `json:"points"`
+}
+
+// Kinds of spooled metrics
+const (
+	spoolGauge                = "gauge"
+	spoolSum                  = "sum"
+	spoolHistogram            = "histogram"
+	spoolExponentialHistogram = "exponential_histogram"
+)
+
+// spoolPoint is the on-disk form of a gauge or sum data point
+type spoolPoint[N int64 | float64] struct {
+	Attributes []spoolAttribute `json:"attributes,omitempty"`
+	StartTime  time.Time        `json:"start_time"`
+	Time       time.Time        `json:"time"`
+	Value      N                `json:"value"`
+}
+
+// spoolHistogramPoint is the on-disk form of a histogram data point
+type spoolHistogramPoint[N int64 | float64] struct {
+	Attributes   []spoolAttribute `json:"attributes,omitempty"`
+	StartTime    time.Time        `json:"start_time"`
+	Time         time.Time        `json:"time"`
+	Count        uint64           `json:"count"`
+	Bounds       []float64        `json:"bounds"`
+	BucketCounts []uint64         `json:"bucket_counts"`
+	Min          *N               `json:"min,omitempty"`
+	Max          *N               `json:"max,omitempty"`
+	Sum          N                `json:"sum"`
+}
+
+// spoolExponentialPoint is the on-disk form of an exponential histogram data point
+type spoolExponentialPoint[N int64 | float64] struct {
+	Attributes     []spoolAttribute             `json:"attributes,omitempty"`
+	StartTime      time.Time                    `json:"start_time"`
+	Time           time.Time                    `json:"time"`
+	Count          uint64                       `json:"count"`
+	Min            *N                           `json:"min,omitempty"`
+	Max            *N                           `json:"max,omitempty"`
+	Sum            N                            `json:"sum"`
+	Scale          int32                        `json:"scale"`
+	ZeroCount      uint64                       `json:"zero_count"`
+	PositiveBucket metricdata.ExponentialBucket `json:"positive_bucket"`
+	NegativeBucket metricdata.ExponentialBucket `json:"negative_bucket"`
+	ZeroThreshold  float64                      `json:"zero_threshold"`
+}
+
+// newSpoolResourceMetrics converts a collection to its on-disk form, leaving out
+// metrics of aggregations the SDK doesn't produce
+func newSpoolResourceMetrics(rm *metricdata.ResourceMetrics) (spoolResourceMetrics, error) {
+	var record spoolResourceMetrics
+	if rm.Resource != nil {
+		record.Resource = newSpoolAttributes(rm.Resource.Attributes())
+		record.ResourceSchemaURL = rm.Resource.SchemaURL()
+	}
+	for _, sm := range rm.ScopeMetrics {
+		scope := spoolScopeMetrics{Scope: spoolScope{
+			Name:      sm.Scope.Name,
+			Version:   sm.Scope.Version,
+			SchemaURL: sm.Scope.SchemaURL,
+		}}
+		for _, m := range sm.Metrics {
+			metric, ok, err := newSpoolMetric(m)
+			if err != nil {
+				return spoolResourceMetrics{}, fmt.Errorf("metric %s: %w", m.Name, err)
+			}
+			if ok {
+				scope.Metrics = append(scope.Metrics, metric)
+			}
+		}
+		record.Scopes = append(record.Scopes, scope)
+	}
+	return record, nil
+}
+
+// newSpoolMetric converts a metric to its on-disk form, reporting false for unsupported aggregations
+func newSpoolMetric(m metricdata.Metrics) (spoolMetric, bool, error) {
+	record := spoolMetric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+	var points interface{}
+	switch data := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		record.Kind, record.Number, points = spoolGauge, "int64", newSpoolPoints(data.DataPoints)
+	case metricdata.Gauge[float64]:
+		record.Kind, record.Number, points = spoolGauge, "float64", newSpoolPoints(data.DataPoints)
+	case metricdata.Sum[int64]:
+		record.Kind, record.Number, points = spoolSum, "int64", newSpoolPoints(data.DataPoints)
+		record.Temporality, record.Monotonic = newSpoolTemporality(data.Temporality), data.IsMonotonic
+	case metricdata.Sum[float64]:
+		record.Kind, record.Number, points = spoolSum, "float64", newSpoolPoints(data.DataPoints)
+		record.Temporality, record.Monotonic = newSpoolTemporality(data.Temporality), data.IsMonotonic
+	case metricdata.Histogram[int64]:
+		record.Kind, record.Number, points = spoolHistogram, "int64", newSpoolHistogramPoints(data.DataPoints)
+		record.Temporality = newSpoolTemporality(data.Temporality)
+	case metricdata.Histogram[float64]:
+		record.Kind, record.Number, points = spoolHistogram, "float64", newSpoolHistogramPoints(data.DataPoints)
+		record.Temporality = newSpoolTemporality(data.Temporality)
+	case metricdata.ExponentialHistogram[int64]:
+		record.Kind, record.Number, points = spoolExponentialHistogram, "int64", newSpoolExponentialPoints(data.DataPoints)
+		record.Temporality = newSpoolTemporality(data.Temporality)
+	case metricdata.ExponentialHistogram[float64]:
+		record.Kind, record.Number, points = spoolExponentialHistogram, "float64", newSpoolExponentialPoints(data.DataPoints)
+		record.Temporality = newSpoolTemporality(data.Temporality)
+	default:
+		return spoolMetric{}, false, nil
+	}
+
+	var err error
+	if record.Points, err = json.Marshal(points); err != nil {
+		return spoolMetric{}, false, err
+	}
+	return record, true, nil
+}
+
+// resourceMetrics converts the on-disk form back to a collection
+func (r spoolResourceMetrics) resourceMetrics() (*metricdata.ResourceMetrics, error) {
+	attrs, err := spoolAttributes(r.Resource)
+	if err != nil {
+		return nil, err
+	}
+	rm := &metricdata.ResourceMetrics{Resource: resource.NewWithAttributes(r.ResourceSchemaURL, attrs...)}
+	for _, scope := range r.Scopes {
+		sm := metricdata.ScopeMetrics{Scope: instrumentation.Scope{
+			Name:      scope.Scope.Name,
+			Version:   scope.Scope.Version,
+			SchemaURL: scope.Scope.SchemaURL,
+		}}
+		for _, record := range scope.Metrics {
+			m, err := record.metrics()
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: %w", record.Name, err)
+			}
+			sm.Metrics = append(sm.Metrics, m)
+		}
+		rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+	}
+	return rm, nil
+}
+
+// metrics converts the on-disk form back to a metric
+func (r spoolMetric) metrics() (metricdata.Metrics, error) {
+	m := metricdata.Metrics{Name: r.Name, Description: r.Description, Unit: r.Unit}
+	var err error
+	switch r.Number {
+	case "int64":
+		m.Data, err = spoolAggregation[int64](r)
+	case "float64":
+		m.Data, err = spoolAggregation[float64](r)
+	default:
+		err = fmt.Errorf("unsupported number type %q", r.Number)
+	}
+	return m, err
+}
+
+// spoolAggregation decodes the points of the on-disk metric into its aggregation
+func spoolAggregation[N int64 | float64](r spoolMetric) (metricdata.Aggregation, error) {
+	switch r.Kind {
+	case spoolGauge, spoolSum:
+		var records []spoolPoint[N]
+		if err := json.Unmarshal(r.Points, &records); err != nil {
+			return nil, err
+		}
+		points := make([]metricdata.DataPoint[N], len(records))
+		for i, p := range records {
+			attrs, err := spoolAttributes(p.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			points[i] = metricdata.DataPoint[N]{
+				Attributes: attribute.NewSet(attrs...),
+				StartTime:  p.StartTime,
+				Time:       p.Time,
+				Value:      p.Value,
+			}
+		}
+		if r.Kind == spoolGauge {
+			return metricdata.Gauge[N]{DataPoints: points}, nil
+		}
+		return metricdata.Sum[N]{DataPoints: points, Temporality: spoolTemporality(r.Temporality), IsMonotonic: r.Monotonic}, nil
+
+	case spoolHistogram:
+		var records []spoolHistogramPoint[N]
+		if err := json.Unmarshal(r.Points, &records); err != nil {
+			return nil, err
+		}
+		points := make([]metricdata.HistogramDataPoint[N], len(records))
+		for i, p := range records {
+			attrs, err := spoolAttributes(p.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			points[i] = metricdata.HistogramDataPoint[N]{
+				Attributes:   attribute.NewSet(attrs...),
+				StartTime:    p.StartTime,
+				Time:         p.Time,
+				Count:        p.Count,
+				Bounds:       p.Bounds,
+				BucketCounts: p.BucketCounts,
+				Min:          spoolExtrema(p.Min),
+				Max:          spoolExtrema(p.Max),
+				Sum:          p.Sum,
+			}
+		}
+		return metricdata.Histogram[N]{DataPoints: points, Temporality: spoolTemporality(r.Temporality)}, nil
+
+	case spoolExponentialHistogram:
+		var records []spoolExponentialPoint[N]
+		if err := json.Unmarshal(r.Points, &records); err != nil {
+			return nil, err
+		}
+		points := make([]metricdata.ExponentialHistogramDataPoint[N], len(records))
+		for i, p := range records {
+			attrs, err := spoolAttributes(p.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			points[i] = metricdata.ExponentialHistogramDataPoint[N]{
+				Attributes:     attribute.NewSet(attrs...),
+				StartTime:      p.StartTime,
+				Time:           p.Time,
+				Count:          p.Count,
+				Min:            spoolExtrema(p.Min),
+				Max:            spoolExtrema(p.Max),
+				Sum:            p.Sum,
+				Scale:          p.Scale,
+				ZeroCount:      p.ZeroCount,
+				PositiveBucket: p.PositiveBucket,
+				NegativeBucket: p.NegativeBucket,
+				ZeroThreshold:  p.ZeroThreshold,
+			}
+		}
+		return metricdata.ExponentialHistogram[N]{DataPoints: points, Temporality: spoolTemporality(r.Temporality)}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported metric kind %q", r.Kind)
+	}
+}
+
+// newSpoolPoints converts gauge or sum data points to their on-disk form
+func newSpoolPoints[N int64 | float64](dps []metricdata.DataPoint[N]) []spoolPoint[N] {
+	points := make([]spoolPoint[N], len(dps))
+	for i, dp := range dps {
+		points[i] = spoolPoint[N]{
+			Attributes: newSpoolAttributes(dp.Attributes.ToSlice()),
+			StartTime:  dp.StartTime,
+			Time:       dp.Time,
+			Value:      dp.Value,
+		}
+	}
+	return points
+}
+
+// newSpoolHistogramPoints converts histogram data points to their on-disk form
+func newSpoolHistogramPoints[N int64 | float64](dps []metricdata.HistogramDataPoint[N]) []spoolHistogramPoint[N] {
+	points := make([]spoolHistogramPoint[N], len(dps))
+	for i, dp := range dps {
+		points[i] = spoolHistogramPoint[N]{
+			Attributes:   newSpoolAttributes(dp.Attributes.ToSlice()),
+			StartTime:    dp.StartTime,
+			Time:         dp.Time,
+			Count:        dp.Count,
+			Bounds:       dp.Bounds,
+			BucketCounts: dp.BucketCounts,
+			Min:          newSpoolExtrema(dp.Min),
+			Max:          newSpoolExtrema(dp.Max),
+			Sum:          dp.Sum,
+		}
+	}
+	return points
+}
+
+// newSpoolExponentialPoints converts exponential histogram data points to their on-disk form
+func newSpoolExponentialPoints[N int64 | float64](dps []metricdata.ExponentialHistogramDataPoint[N]) []spoolExponentialPoint[N] {
+	points := make([]spoolExponentialPoint[N], len(dps))
+	for i, dp := range dps {
+		points[i] = spoolExponentialPoint[N]{
+			Attributes:     newSpoolAttributes(dp.Attributes.ToSlice()),
+			StartTime:      dp.StartTime,
+			Time:           dp.Time,
+			Count:          dp.Count,
+			Min:            newSpoolExtrema(dp.Min),
+			Max:            newSpoolExtrema(dp.Max),
+			Sum:            dp.Sum,
+			Scale:          dp.Scale,
+			ZeroCount:      dp.ZeroCount,
+			PositiveBucket: dp.PositiveBucket,
+			NegativeBucket: dp.NegativeBucket,
+			ZeroThreshold:  dp.ZeroThreshold,
+		}
+	}
+	return points
+}
+
+// newSpoolTemporality names a temporality for the on-disk form; Temporality encodes as
+// its name but can't decode from it
+func newSpoolTemporality(t metricdata.Temporality) string {
+	switch t {
+	case metricdata.DeltaTemporality:
+		return "delta"
+	case metricdata.CumulativeTemporality:
+		return "cumulative"
+	default:
+		return ""
+	}
+}
+
+// spoolTemporality converts an on-disk temporality name back
+func spoolTemporality(name string) metricdata.Temporality {
+	switch name {
+	case "delta":
+		return metricdata.DeltaTemporality
+	case "cumulative":
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.Temporality(0)
+	}
+}
+
+// newSpoolExtrema returns the extremum's value, or nil if it was not recorded
+func newSpoolExtrema[N int64 | float64](e metricdata.Extrema[N]) *N {
+	if v, ok := e.Value(); ok {
+		return &v
+	}
+	return nil
+}
+
+// spoolExtrema converts an on-disk extremum back, leaving it undefined if nil
+func spoolExtrema[N int64 | float64](v *N) metricdata.Extrema[N] {
+	if v == nil {
+		return metricdata.Extrema[N]{}
+	}
+	return metricdata.NewExtrema(*v)
+}
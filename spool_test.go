@@ -0,0 +1,179 @@
+package observability
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata/metricdatatest"
+)
+
+// spooledFiles counts the batches waiting in dir
+func spooledFiles(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestFileSpoolExporterReplaysAfterRecovery(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &flakySpanExporter{down: true}
+	exporter, err := NewFileSpoolExporter(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exporter.ExportSpans(ctx, spanStubs("first", "second")); err != nil {
+		t.Fatalf("ExportSpans() while down = %v, want the batch spooled", err)
+	}
+	if n := spooledFiles(t, dir); n != 1 {
+		t.Fatalf("spooled %d batches, want 1", n)
+	}
+
+	inner.down = false
+	if err := exporter.ExportSpans(ctx, spanStubs("third")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"third", "first", "second"}
+	if len(inner.names) != len(want) {
+		t.Fatalf("exported %v, want %v", inner.names, want)
+	}
+	for i := range want {
+		if inner.names[i] != want[i] {
+			t.Fatalf("exported %v, want %v", inner.names, want)
+		}
+	}
+	if n := spooledFiles(t, dir); n != 0 {
+		t.Errorf("%d batches left in the spool, want 0", n)
+	}
+}
+
+func TestFileSpoolExporterBoundsReplayPerExport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &flakySpanExporter{down: true}
+	exporter, err := NewFileSpoolExporter(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxReplayBatches+2; i++ {
+		if err := exporter.ExportSpans(ctx, spanStubs("spooled")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inner.down = false
+	if err := exporter.ExportSpans(ctx, spanStubs("live")); err != nil {
+		t.Fatal(err)
+	}
+	if n := spooledFiles(t, dir); n != 2 {
+		t.Fatalf("%d batches left after one export, want 2", n)
+	}
+	if err := exporter.ExportSpans(ctx, spanStubs("live")); err != nil {
+		t.Fatal(err)
+	}
+	if n := spooledFiles(t, dir); n != 0 {
+		t.Fatalf("%d batches left after two exports, want 0", n)
+	}
+	if len(inner.names) != maxReplayBatches+4 {
+		t.Errorf("exported %d spans, want %d", len(inner.names), maxReplayBatches+4)
+	}
+}
+
+func TestFileSpoolExporterDropsCorruptFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0"+spoolFileExt), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	inner := &flakySpanExporter{down: true}
+	exporter, err := NewFileSpoolExporter(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.ExportSpans(ctx, spanStubs("spooled")); err != nil {
+		t.Fatal(err)
+	}
+
+	inner.down = false
+	if err := exporter.ExportSpans(ctx, spanStubs("live")); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.names) != 2 || inner.names[1] != "spooled" {
+		t.Errorf("exported %v, want the spooled span after the corrupt file", inner.names)
+	}
+	if n := spooledFiles(t, dir); n != 0 {
+		t.Errorf("%d batches left in the spool, want 0", n)
+	}
+}
+
+func TestFileSpoolMetricExporterReplaysAfterRecovery(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("spool-test")
+
+	counter, err := meter.Int64Counter("requests", metric.WithUnit("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram, err := meter.Float64Histogram("latency", metric.WithUnit("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gauge, err := meter.Float64Gauge("temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := metric.WithAttributes(attribute.String("route", "/users"))
+	counter.Add(ctx, 3, attrs)
+	histogram.Record(ctx, 0.25, attrs)
+	histogram.Record(ctx, 1.5, attrs)
+	gauge.Record(ctx, 21.5)
+
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &collected); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	inner := &flakyMetricExporter{down: true}
+	exporter, err := NewFileSpoolMetricExporter(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Export(ctx, &collected); err != nil {
+		t.Fatalf("Export() while down = %v, want the collection spooled", err)
+	}
+	if n := spooledFiles(t, dir); n != 1 {
+		t.Fatalf("spooled %d collections, want 1", n)
+	}
+
+	inner.down = false
+	if err := exporter.Export(ctx, &metricdata.ResourceMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.exported) != 2 {
+		t.Fatalf("exported %d collections, want the live one and the replayed one", len(inner.exported))
+	}
+	replayed := inner.exported[1]
+	if got, want := replayed.Resource.Attributes(), collected.Resource.Attributes(); len(got) != len(want) {
+		t.Errorf("replayed resource has %d attributes, want %d", len(got), len(want))
+	}
+	if len(replayed.ScopeMetrics) != 1 {
+		t.Fatalf("replayed %d scopes, want 1", len(replayed.ScopeMetrics))
+	}
+	metricdatatest.AssertEqual(t, collected.ScopeMetrics[0], replayed.ScopeMetrics[0])
+	if n := spooledFiles(t, dir); n != 0 {
+		t.Errorf("%d collections left in the spool, want 0", n)
+	}
+}
@@ -0,0 +1,122 @@
+package observability
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// seriesKey identifies a series by instrument name and attribute set
+type seriesKey struct {
+	name  string
+	attrs attribute.Distinct
+}
+
+// seriesState is the last exported value of a series and when something was last added to it
+type seriesState struct {
+	value  float64
+	active time.Time
+}
+
+// stalenessExporter drops cumulative counter series nothing was added to for longer
+// than the TTL, so idle attribute sets stop being exported. A counter only grows when
+// something is recorded, so its value tells when it was last active. A series that
+// is added to again is exported again with its full cumulative value.
+type stalenessExporter struct {
+	sdkmetric.Exporter
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	series map[seriesKey]seriesState
+}
+
+// newStalenessExporter wraps next so cumulative series idle for longer than ttl are not exported
+func newStalenessExporter(next sdkmetric.Exporter, ttl time.Duration) *stalenessExporter {
+	return &stalenessExporter{
+		Exporter: next,
+		ttl:      ttl,
+		now:      time.Now,
+		series:   make(map[seriesKey]seriesState),
+	}
+}
+
+// Export removes stale series from rm before passing it to the wrapped exporter
+func (e *stalenessExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	now := e.now()
+	// Series are re-tracked on every export, which forgets instruments that disappeared
+	seen := make(map[seriesKey]seriesState, len(e.series))
+
+	scopes := rm.ScopeMetrics[:0]
+	for _, sm := range rm.ScopeMetrics {
+		metrics := sm.Metrics[:0]
+		for _, m := range sm.Metrics {
+			if data, ok := e.prune(m.Name, m.Data, now, seen); ok {
+				m.Data = data
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			sm.Metrics = metrics
+			scopes = append(scopes, sm)
+		}
+	}
+	rm.ScopeMetrics = scopes
+
+	e.series = seen
+	e.mu.Unlock()
+
+	return e.Exporter.Export(ctx, rm)
+}
+
+// prune returns the aggregation without its stale data points, and false if none remain.
+// Only cumulative monotonic sums are pruned: an unchanged up-down counter may still be
+// current, and histograms and gauges are left to the backend.
+func (e *stalenessExporter) prune(name string, data metricdata.Aggregation, now time.Time, seen map[seriesKey]seriesState) (metricdata.Aggregation, bool) {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		if !d.IsMonotonic || d.Temporality != metricdata.CumulativeTemporality {
+			return d, true
+		}
+		d.DataPoints = pruneDataPoints(e, name, d.DataPoints, now, seen, func(dp metricdata.DataPoint[int64]) (attribute.Set, float64) {
+			return dp.Attributes, float64(dp.Value)
+		})
+		return d, len(d.DataPoints) > 0
+	case metricdata.Sum[float64]:
+		if !d.IsMonotonic || d.Temporality != metricdata.CumulativeTemporality {
+			return d, true
+		}
+		d.DataPoints = pruneDataPoints(e, name, d.DataPoints, now, seen, func(dp metricdata.DataPoint[float64]) (attribute.Set, float64) {
+			return dp.Attributes, dp.Value
+		})
+		return d, len(d.DataPoints) > 0
+	default:
+		return data, true
+	}
+}
+
+// pruneDataPoints keeps the data points that were added to within the TTL, recording their state in seen
+func pruneDataPoints[DP any](e *stalenessExporter, name string, points []DP, now time.Time, seen map[seriesKey]seriesState, value func(DP) (attribute.Set, float64)) []DP {
+	kept := points[:0]
+	for _, dp := range points {
+		attrs, v := value(dp)
+		key := seriesKey{name: name, attrs: attrs.Equivalent()}
+
+		state, exists := e.series[key]
+		if !exists || v != state.value {
+			state.active = now
+		}
+		state.value = v
+		seen[key] = state
+
+		if now.Sub(state.active) <= e.ttl {
+			kept = append(kept, dp)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,143 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestStalenessExporterPrunesIdleSeries(t *testing.T) {
+	ctx := context.Background()
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	exporter := newStalenessExporter(discardMetricExporter(t), time.Minute)
+	exporter.now = clock.Now
+
+	// export collects and exports, returning the exported request counts by tenant
+	export := func() map[string]int64 {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.Export(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		counts := make(map[string]int64)
+		for _, sm := range rm.ScopeMetrics {
+			for _, metric := range sm.Metrics {
+				for _, dp := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+					tenant, _ := dp.Attributes.Value("tenant")
+					counts[tenant.AsString()] = dp.Value
+				}
+			}
+		}
+		return counts
+	}
+	increment := func(tenant string) {
+		t.Helper()
+		if err := m.IncrementCounter(ctx, "requests", 1, attribute.String("tenant", tenant)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		advance    time.Duration
+		increments []string
+		want       map[string]int64
+	}{
+		{0, []string{"acme", "globex"}, map[string]int64{"acme": 1, "globex": 1}},
+		{30 * time.Second, []string{"acme"}, map[string]int64{"acme": 2, "globex": 1}},
+		// globex has been idle for 75s, past the TTL
+		{45 * time.Second, []string{"acme"}, map[string]int64{"acme": 3}},
+		// A series that changes again comes back with its cumulative value
+		{10 * time.Second, []string{"globex"}, map[string]int64{"acme": 3, "globex": 2}},
+	}
+	for i, step := range steps {
+		clock.advance(step.advance)
+		for _, tenant := range step.increments {
+			increment(tenant)
+		}
+		got := export()
+		if len(got) != len(step.want) {
+			t.Errorf("step %d exported %v, want %v", i, got, step.want)
+			continue
+		}
+		for tenant, want := range step.want {
+			if got[tenant] != want {
+				t.Errorf("step %d exported %v, want %v", i, got, step.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStalenessExporterKeepsDeltaSeries(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	exporter := newStalenessExporter(discardMetricExporter(t), time.Minute)
+	exporter.now = clock.Now
+
+	delta := metricdata.Sum[int64]{
+		Temporality: metricdata.DeltaTemporality,
+		DataPoints:  []metricdata.DataPoint[int64]{{Value: 0}},
+	}
+	for i := 0; i < 3; i++ {
+		rm := metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{{Name: "requests", Data: delta}},
+		}}}
+		if err := exporter.Export(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		if len(rm.ScopeMetrics) != 1 {
+			t.Fatalf("export %d dropped a delta series", i)
+		}
+		clock.advance(2 * time.Minute)
+	}
+}
+
+func TestStalenessExporterKeepsUpDownCountersAndHistograms(t *testing.T) {
+	ctx := context.Background()
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	exporter := newStalenessExporter(discardMetricExporter(t), time.Minute)
+	exporter.now = clock.Now
+
+	inFlight, err := m.CreateUpDownCounter("in_flight", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inFlight.Add(ctx, 5)
+	if err := m.RecordHistogram(ctx, "latency", 0.2); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.Export(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		if len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 2 {
+			t.Fatalf("export %d = %v, want the idle up-down counter and histogram kept", i, rm.ScopeMetrics)
+		}
+		clock.advance(2 * time.Minute)
+	}
+}
+
+// discardMetricExporter returns an exporter that drops everything it exports
+func discardMetricExporter(t *testing.T) sdkmetric.Exporter {
+	t.Helper()
+	exporter, err := stdoutmetric.New(stdoutmetric.WithWriter(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exporter
+}
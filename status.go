@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+)
+
+// SetHTTPStatus records an HTTP response status code on the span, marking 5xx responses as errors.
+// Other codes leave the span status unset, as the semantic conventions require.
+func SetHTTPStatus(span trace.Span, code int) {
+	span.SetAttributes(attribute.Int("http.status_code", code))
+	if code >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+}
+
+// SetGRPCStatus records a gRPC status code on the span, marking every non-OK code as an error
+func SetGRPCStatus(span trace.Span, code grpccodes.Code) {
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	if code != grpccodes.OK {
+		span.SetStatus(codes.Error, code.String())
+	}
+}
+
+// HTTPServerAttributes returns the semantic-convention attributes describing an incoming
+// request. The route is included once a mux has matched the request.
+func HTTPServerAttributes(r *http.Request) []attribute.KeyValue {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("http.method", r.Method),
+		attribute.String("url.path", r.URL.Path),
+		attribute.String("url.scheme", scheme),
+		attribute.String("server.address", r.Host),
+	}
+	if r.Pattern != "" {
+		attrs = append(attrs, attribute.String("http.route", r.Pattern))
+	}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		attrs = append(attrs, attribute.String("user_agent.original", userAgent))
+	}
+	return attrs
+}
+
+// HTTPClientAttributes returns the semantic-convention attributes describing an outgoing
+// request and, if it is not nil, its response. Credentials in the URL are redacted.
+func HTTPClientAttributes(req *http.Request, resp *http.Response) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.Redacted()),
+		attribute.String("url.path", req.URL.Path),
+		attribute.String("url.scheme", req.URL.Scheme),
+		attribute.String("server.address", req.URL.Hostname()),
+	}
+	if resp != nil {
+		attrs = append(attrs, attribute.Int("http.status_code", resp.StatusCode))
+	}
+	return attrs
+}
@@ -0,0 +1,19 @@
+package observability
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testTracerName is the instrumentation name used by NewTestTracer
+const testTracerName = "test"
+
+// NewTestTracer creates a Tracer backed by its own provider that samples every span
+// and a recorder holding the spans it starts, so tests can assert on names,
+// attributes and status without touching the global provider
+func NewTestTracer() (*Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	return NewTracerFromProvider(tp, testTracerName), recorder
+}
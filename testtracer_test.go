@@ -0,0 +1,23 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func ExampleNewTestTracer() {
+	tracer, recorder := NewTestTracer()
+
+	_, span := tracer.Start(context.Background(), "charge-card")
+	span.SetAttributes(attribute.String("card.brand", "visa"))
+	span.SetStatus(codes.Error, "declined")
+	span.End()
+
+	for _, s := range recorder.Ended() {
+		fmt.Println(s.Name(), s.Attributes()[0].Value.AsString(), s.Status().Code, s.Status().Description)
+	}
+	// Output: charge-card visa Error declined
+}
@@ -2,15 +2,48 @@ package observability
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// TracerInterface is the tracing surface of Tracer, for code that wants to accept a mock
+type TracerInterface interface {
+	Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span)
+	StartAt(ctx context.Context, name string, startTime time.Time, opts ...trace.SpanStartOption) (context.Context, trace.Span)
+	EndAt(span trace.Span, endTime time.Time, opts ...trace.SpanEndOption)
+	StartManaged(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, func())
+	StartFromTraceparent(ctx context.Context, traceparent, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span, error)
+	RecordError(ctx context.Context, err error, attrs ...attribute.KeyValue)
+	WithAttributes(attrs ...attribute.KeyValue) TracerInterface
+	HTTPMiddleware(next http.Handler) http.Handler
+	RoundTripper(base http.RoundTripper) http.RoundTripper
+	GetTracer() trace.Tracer
+	GetName() string
+	GetTraceID(ctx context.Context) string
+	GetSpanID(ctx context.Context) string
+	IsRecording(ctx context.Context) bool
+	SpanContext(ctx context.Context) trace.SpanContext
+	ForceFlush(ctx context.Context) error
+}
+
+var _ TracerInterface = (*Tracer)(nil)
+
 // Tracer provides a simplified interface for tracing
 type Tracer struct {
 	tracer trace.Tracer
 	name   string
+	attrs  []attribute.KeyValue
+	// provider is the provider the tracer was created from, or nil for the global one
+	provider trace.TracerProvider
 }
 
 // NewTracer creates a new Tracer instance
@@ -21,11 +54,127 @@ func NewTracer(name string) *Tracer {
 	}
 }
 
+// NewTracerFromProvider creates a new Tracer that starts spans on the given provider
+// instead of the global one. Options such as trace.WithInstrumentationVersion describe
+// the instrumentation scope.
+func NewTracerFromProvider(tp trace.TracerProvider, name string, opts ...trace.TracerOption) *Tracer {
+	return &Tracer{
+		tracer:   tp.Tracer(name, opts...),
+		name:     name,
+		provider: tp,
+	}
+}
+
+// NewTracerWithAttributes creates a new Tracer that applies the given attributes to every span it starts
+func NewTracerWithAttributes(name string, attrs ...attribute.KeyValue) *Tracer {
+	tracer := NewTracer(name)
+	tracer.attrs = attrs
+	return tracer
+}
+
+// withAttributes returns a copy of the Tracer that also applies attrs to every span it starts
+func (t *Tracer) withAttributes(attrs ...attribute.KeyValue) *Tracer {
+	c := *t
+	c.attrs = append(append([]attribute.KeyValue{}, t.attrs...), attrs...)
+	return &c
+}
+
+// WithAttributes returns a tracer that also applies attrs to every span it starts
+func (t *Tracer) WithAttributes(attrs ...attribute.KeyValue) TracerInterface {
+	return t.withAttributes(attrs...)
+}
+
 // Start starts a new span
 func (t *Tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if len(t.attrs) > 0 {
+		// Default attributes go first so per-call attributes with the same key win
+		opts = append([]trace.SpanStartOption{trace.WithAttributes(t.attrs...)}, opts...)
+	}
 	return t.tracer.Start(ctx, name, opts...)
 }
 
+// StartAt starts a new span that began at startTime, for work that was under way
+// before the span could be started
+func (t *Tracer) StartAt(ctx context.Context, name string, startTime time.Time, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	// Copy so the timestamp isn't appended into the caller's backing array
+	opts = append(append([]trace.SpanStartOption{}, opts...), trace.WithTimestamp(startTime))
+	return t.Start(ctx, name, opts...)
+}
+
+// EndAt ends the span as of endTime rather than now
+func (t *Tracer) EndAt(span trace.Span, endTime time.Time, opts ...trace.SpanEndOption) {
+	span.End(append(append([]trace.SpanEndOption{}, opts...), trace.WithTimestamp(endTime))...)
+}
+
+// StartManaged starts a new span and returns a func that ends it. The func is safe to
+// call more than once, so it can be deferred and also called early on a fast path.
+func (t *Tracer) StartManaged(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, func()) {
+	ctx, span := t.Start(ctx, name, opts...)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() { span.End() })
+	}
+}
+
+// ErrInvalidTraceparent is returned when a traceparent is not a valid W3C trace context
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// StartFromTraceparent starts a span continuing the trace in a stored W3C traceparent,
+// e.g. one saved alongside a queued job, with the stored span as its remote parent.
+// Baggage and log fields in ctx are kept.
+func (t *Tracer) StartFromTraceparent(ctx context.Context, traceparent, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span, error) {
+	carrier := propagation.MapCarrier{"traceparent": traceparent}
+	parent := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+	if !parent.IsValid() {
+		// A non-recording span keeps a deferred End safe even when the error is ignored
+		return ctx, trace.SpanFromContext(context.Background()), fmt.Errorf("%w: %q", ErrInvalidTraceparent, traceparent)
+	}
+	ctx, span := t.Start(trace.ContextWithRemoteSpanContext(ctx, parent), name, opts...)
+	return ctx, span, nil
+}
+
+// RecordError records err as an exception event carrying attrs on the span in ctx and
+// marks the span as failed. Errors joined with errors.Join are recorded as one event
+// per joined error. A nil err records nothing.
+func (t *Tracer) RecordError(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	if err == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	for _, e := range flattenJoined(err) {
+		span.RecordError(e, trace.WithAttributes(attrs...))
+	}
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// flattenJoined returns the leaf errors of errors joined with errors.Join, or err itself
+func flattenJoined(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		if e != nil {
+			errs = append(errs, flattenJoined(e)...)
+		}
+	}
+	return errs
+}
+
+// ForceFlush exports all ended spans that have not been exported yet. It does nothing
+// if the tracer's provider cannot be flushed, e.g. when no SDK provider is registered.
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	provider := t.provider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	if flusher, ok := provider.(interface{ ForceFlush(context.Context) error }); ok {
+		return flusher.ForceFlush(ctx)
+	}
+	return nil
+}
+
 // GetTracer returns the underlying OpenTelemetry tracer
 func (t *Tracer) GetTracer() trace.Tracer {
 	return t.tracer
@@ -53,3 +202,13 @@ func (t *Tracer) GetSpanID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// IsRecording reports whether the span in the context is recording
+func (t *Tracer) IsRecording(ctx context.Context) bool {
+	return trace.SpanFromContext(ctx).IsRecording()
+}
+
+// SpanContext returns the span context of the span in the context
+func (t *Tracer) SpanContext(ctx context.Context) trace.SpanContext {
+	return trace.SpanContextFromContext(ctx)
+}
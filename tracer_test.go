@@ -0,0 +1,273 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestIsRecordingAndSpanContext(t *testing.T) {
+	sampled, _ := NewTestTracer()
+	unsampled := NewTracerFromProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())), "test")
+
+	sampledCtx, sampledSpan := sampled.Start(context.Background(), "sampled")
+	defer sampledSpan.End()
+	unsampledCtx, unsampledSpan := unsampled.Start(context.Background(), "unsampled")
+	defer unsampledSpan.End()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		recording bool
+		valid     bool
+	}{
+		{"sampled span", sampledCtx, true, true},
+		{"non-recording span", unsampledCtx, false, true},
+		{"no span", context.Background(), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampled.IsRecording(tt.ctx); got != tt.recording {
+				t.Errorf("IsRecording() = %v, want %v", got, tt.recording)
+			}
+			if got := sampled.SpanContext(tt.ctx).IsValid(); got != tt.valid {
+				t.Errorf("SpanContext().IsValid() = %v, want %v", got, tt.valid)
+			}
+		})
+	}
+	if got := sampled.SpanContext(sampledCtx); !got.Equal(sampledSpan.SpanContext()) {
+		t.Errorf("SpanContext() = %v, want the active span's", got)
+	}
+}
+
+// useGlobalRecorder registers a provider recording every span as the global one for the test
+func useGlobalRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	return recorder
+}
+
+func TestNewTracerWithAttributes(t *testing.T) {
+	base, recorder := NewTestTracer()
+	tracer := base.withAttributes(
+		attribute.String("service.instance.id", "pod-1"),
+		attribute.String("deploy.sha", "abc123"),
+	)
+
+	_, span := tracer.Start(context.Background(), "work",
+		trace.WithAttributes(attribute.String("deploy.sha", "override"), attribute.Int("items", 3)))
+	span.End()
+
+	attrs := attribute.NewSet(recorder.Ended()[0].Attributes()...)
+	want := map[attribute.Key]string{"service.instance.id": "pod-1", "deploy.sha": "override", "items": "3"}
+	for key, value := range want {
+		if v, _ := attrs.Value(key); v.Emit() != value {
+			t.Errorf("%s = %q, want %q", key, v.Emit(), value)
+		}
+	}
+}
+
+func TestNewTracerWithAttributesUsesGlobalProvider(t *testing.T) {
+	recorder := useGlobalRecorder(t)
+	tracer := NewTracerWithAttributes("test", attribute.String("deploy.sha", "abc123"))
+
+	_, span := tracer.Start(context.Background(), "work")
+	span.End()
+
+	attrs := attribute.NewSet(recorder.Ended()[0].Attributes()...)
+	if v, _ := attrs.Value("deploy.sha"); v.AsString() != "abc123" {
+		t.Errorf("deploy.sha = %q, want the default attribute", v.AsString())
+	}
+}
+
+func TestStartManaged(t *testing.T) {
+	tracer, recorder := NewTestTracer()
+
+	ctx, end := tracer.StartManaged(context.Background(), "work")
+	if span := trace.SpanFromContext(ctx); !span.IsRecording() {
+		t.Fatal("returned context has no recording span")
+	}
+	end()
+	end()
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d ended spans, want 1", len(ended))
+	}
+	if got, want := ended[0].SpanContext(), trace.SpanContextFromContext(ctx); !got.Equal(want) {
+		t.Errorf("ended span %v, want the span in the returned context %v", got, want)
+	}
+}
+
+func TestStartAtEndAt(t *testing.T) {
+	tracer, recorder := NewTestTracer()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(250 * time.Millisecond)
+
+	// Spare capacity would let an append write the timestamp into the caller's array
+	startOpts := make([]trace.SpanStartOption, 1, 4)
+	startOpts[0] = trace.WithSpanKind(trace.SpanKindClient)
+	startOpts[:2][1] = trace.WithSpanKind(trace.SpanKindProducer)
+	endOpts := make([]trace.SpanEndOption, 0, 4)
+	endOpts[:1][0] = trace.WithStackTrace(true)
+
+	_, span := tracer.StartAt(context.Background(), "ttfb", start, startOpts...)
+	tracer.EndAt(span, end, endOpts...)
+
+	if config := trace.NewSpanStartConfig(startOpts[:2][1]); config.SpanKind() != trace.SpanKindProducer {
+		t.Error("caller's start options were overwritten")
+	}
+	if config := trace.NewSpanEndConfig(endOpts[:1][0]); !config.StackTrace() {
+		t.Error("caller's end options were overwritten")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if got := spans[0].StartTime(); !got.Equal(start) {
+		t.Errorf("start time = %v, want %v", got, start)
+	}
+	if got := spans[0].EndTime(); !got.Equal(end) {
+		t.Errorf("end time = %v, want %v", got, end)
+	}
+	if got := spans[0].SpanKind(); got != trace.SpanKindClient {
+		t.Errorf("span kind = %v, want the caller's option applied", got)
+	}
+}
+
+func TestRecordErrorJoined(t *testing.T) {
+	tracer, recorder := NewTestTracer()
+	ctx, span := tracer.Start(context.Background(), "batch")
+
+	err := errors.Join(errors.New("row 3 invalid"), errors.Join(errors.New("row 7 invalid"), errors.New("row 9 invalid")))
+	tracer.RecordError(ctx, err, attribute.String("table", "orders"))
+	tracer.RecordError(ctx, nil)
+	span.End()
+
+	ended := recorder.Ended()[0]
+	if ended.Status().Code != codes.Error {
+		t.Errorf("status = %v, want Error", ended.Status().Code)
+	}
+	events := ended.Events()
+	want := []string{"row 3 invalid", "row 7 invalid", "row 9 invalid"}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want one per joined error", len(events))
+	}
+	for i, event := range events {
+		attrs := attribute.NewSet(event.Attributes...)
+		if message, _ := attrs.Value("exception.message"); message.AsString() != want[i] {
+			t.Errorf("event %d exception.message = %q, want %q", i, message.AsString(), want[i])
+		}
+		if table, _ := attrs.Value("table"); table.AsString() != "orders" {
+			t.Errorf("event %d table = %q, want the extra attribute", i, table.AsString())
+		}
+	}
+}
+
+func TestNewTracerFromProvider(t *testing.T) {
+	global := useGlobalRecorder(t)
+	bound := tracetest.NewSpanRecorder()
+	tracer := NewTracerFromProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(bound)), "payments",
+		trace.WithInstrumentationVersion("2.1.0"))
+
+	_, span := tracer.Start(context.Background(), "charge")
+	span.End()
+
+	if spans := global.Ended(); len(spans) != 0 {
+		t.Errorf("global provider recorded %d spans, want none", len(spans))
+	}
+	spans := bound.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("bound provider recorded %d spans, want 1", len(spans))
+	}
+	if scope := spans[0].InstrumentationScope(); scope.Name != "payments" || scope.Version != "2.1.0" {
+		t.Errorf("scope = %+v, want the tracer's name and version", scope)
+	}
+}
+
+func TestIsSampled(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler sdktrace.Sampler
+		want    bool
+	}{
+		{"always", sdktrace.AlwaysSample(), true},
+		{"never", sdktrace.NeverSample(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := NewTracerFromProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(tt.sampler)), "test")
+			ctx, span := tracer.Start(context.Background(), "lookup")
+			defer span.End()
+
+			if got := IsSampled(ctx); got != tt.want {
+				t.Errorf("IsSampled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if IsSampled(context.Background()) {
+		t.Error("IsSampled() = true without a span")
+	}
+}
+
+func TestStartFromTraceparent(t *testing.T) {
+	tracer, recorder := NewTestTracer()
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	_, span, err := tracer.StartFromTraceparent(context.Background(), traceparent, "process-job")
+	if err != nil {
+		t.Fatalf("StartFromTraceparent: %v", err)
+	}
+	span.End()
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d ended spans, want 1", len(ended))
+	}
+	if got := ended[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the stored one", got)
+	}
+	parent := ended[0].Parent()
+	if !parent.IsRemote() || parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("parent = %v, want the remote stored span", parent)
+	}
+}
+
+func TestStartFromTraceparentRejectsMalformed(t *testing.T) {
+	tracer, recorder := NewTestTracer()
+
+	for _, traceparent := range []string{
+		"",
+		"not-a-traceparent",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		ctx, span, err := tracer.StartFromTraceparent(context.Background(), traceparent, "process-job")
+		if !errors.Is(err, ErrInvalidTraceparent) {
+			t.Errorf("StartFromTraceparent(%q) = %v, want ErrInvalidTraceparent", traceparent, err)
+		}
+		if span.IsRecording() {
+			t.Errorf("StartFromTraceparent(%q) returned a recording span", traceparent)
+		}
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			t.Errorf("StartFromTraceparent(%q) returned a context with a span", traceparent)
+		}
+		// Ending the returned span is safe and records nothing
+		span.End()
+	}
+	if ended := recorder.Ended(); len(ended) != 0 {
+		t.Errorf("got %d ended spans, want none", len(ended))
+	}
+}
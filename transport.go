@@ -0,0 +1,53 @@
+package observability
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingTransport starts a client span for each outbound request and injects its context
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer *Tracer
+}
+
+// RoundTripper wraps base so outbound requests carry the current trace context.
+// A nil base uses http.DefaultTransport.
+func (t *Tracer) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base, tracer: t}
+}
+
+// RoundTrip executes a single HTTP transaction inside a client span
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.Redacted()),
+		),
+	)
+	defer span.End()
+
+	// A RoundTripper must not modify the caller's request, so inject into a clone
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	SetHTTPStatus(span, resp.StatusCode)
+
+	return resp, nil
+}
@@ -0,0 +1,74 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useTraceContextPropagator installs the W3C propagator globally for the test
+func useTraceContextPropagator(t *testing.T) {
+	t.Helper()
+	original := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(original) })
+}
+
+func TestRoundTripperInjectsTraceContext(t *testing.T) {
+	useTraceContextPropagator(t)
+	tracer, recorder := NewTestTracer()
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, parent := tracer.Start(context.Background(), "caller")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: tracer.RoundTripper(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("the caller's request was modified")
+	}
+
+	var span sdktrace.ReadOnlySpan
+	for _, ended := range recorder.Ended() {
+		if ended.SpanKind() == trace.SpanKindClient {
+			span = ended
+		}
+	}
+	if span == nil {
+		t.Fatal("no client span was recorded")
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("client span is not a child of the caller's span")
+	}
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+	attrs := attribute.NewSet(span.Attributes()...)
+	if v, _ := attrs.Value("http.method"); v.AsString() != http.MethodGet {
+		t.Errorf("http.method = %q, want GET", v.AsString())
+	}
+	if v, _ := attrs.Value("http.url"); v.AsString() != server.URL+"/orders" {
+		t.Errorf("http.url = %q, want the request URL", v.AsString())
+	}
+}
@@ -0,0 +1,98 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instruments recorded by WorkerMetrics, tagged with the worker.pool attribute
+const (
+	workerJobsEnqueuedMetric = "worker.jobs.enqueued"
+	workerJobsActiveMetric   = "worker.jobs.active"
+	workerJobsFailedMetric   = "worker.jobs.failed"
+	workerJobDurationMetric  = "worker.job.duration"
+	workerQueueDepthMetric   = "worker.queue.depth"
+)
+
+// WorkerPoolKey is the attribute identifying the worker pool of worker metrics
+const WorkerPoolKey = attribute.Key("worker.pool")
+
+// WorkerMetrics records standard metrics for a worker pool: enqueued, in-flight and
+// failed jobs, job duration and, when a callback is given, queue depth
+type WorkerMetrics struct {
+	metrics  *Metrics
+	enqueued metric.Int64Counter
+	active   metric.Int64UpDownCounter
+	failed   metric.Int64Counter
+	duration metric.Float64Histogram
+	pool     attribute.KeyValue
+}
+
+// NewWorkerMetrics creates the metrics of the named worker pool. If queueDepth is not
+// nil it is called at every collection to report the number of waiting jobs.
+func (m *Metrics) NewWorkerMetrics(pool string, queueDepth func() float64) (*WorkerMetrics, error) {
+	enqueued, err := m.CreateCounter(workerJobsEnqueuedMetric, "Number of jobs enqueued")
+	if err != nil {
+		return nil, err
+	}
+	active, err := m.CreateUpDownCounter(workerJobsActiveMetric, "Number of jobs being processed")
+	if err != nil {
+		return nil, err
+	}
+	failed, err := m.CreateCounter(workerJobsFailedMetric, "Number of jobs that failed")
+	if err != nil {
+		return nil, err
+	}
+	duration, err := m.CreateHistogram(workerJobDurationMetric, "Duration of jobs", "s")
+	if err != nil {
+		return nil, err
+	}
+
+	poolAttr := WorkerPoolKey.String(pool)
+	if queueDepth != nil {
+		if err := m.addGaugeObserver(workerQueueDepthMetric, "Number of jobs waiting to be processed", queueDepth, poolAttr); err != nil {
+			return nil, fmt.Errorf("failed to create queue depth gauge: %w", err)
+		}
+	}
+
+	return &WorkerMetrics{
+		metrics:  m,
+		enqueued: enqueued,
+		active:   active,
+		failed:   failed,
+		duration: duration,
+		pool:     poolAttr,
+	}, nil
+}
+
+// JobEnqueued counts a job added to the queue
+func (w *WorkerMetrics) JobEnqueued(ctx context.Context) {
+	w.enqueued.Add(w.metrics.recordContext(ctx), 1, w.attributes())
+}
+
+// JobStarted counts a job as in flight and returns its start time for JobFinished
+func (w *WorkerMetrics) JobStarted(ctx context.Context) time.Time {
+	w.active.Add(w.metrics.recordContext(ctx), 1, w.attributes())
+	return w.metrics.clock.Now()
+}
+
+// JobFinished records the duration of a job started at started and counts it as failed if err is not nil
+func (w *WorkerMetrics) JobFinished(ctx context.Context, started time.Time, err error) {
+	ctx = w.metrics.recordContext(ctx)
+	elapsed := w.metrics.clock.Now().Sub(started).Seconds()
+
+	w.active.Add(ctx, -1, w.attributes())
+	w.duration.Record(ctx, elapsed, w.attributes(attribute.Bool("error", err != nil)))
+	if err != nil {
+		w.failed.Add(ctx, 1, w.attributes())
+	}
+}
+
+// attributes returns the measurement options tagging a measurement with the pool
+func (w *WorkerMetrics) attributes(attrs ...attribute.KeyValue) metric.MeasurementOption {
+	return metric.WithAttributes(w.metrics.attributes(append([]attribute.KeyValue{w.pool}, attrs...))...)
+}
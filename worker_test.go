@@ -0,0 +1,66 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestWorkerMetrics(t *testing.T) {
+	m, reader := newTestMetrics(t, MetricsConfig{})
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	m.SetClock(clock)
+	ctx := context.Background()
+
+	worker, err := m.NewWorkerMetrics("emails", func() float64 { return 5 })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		worker.JobEnqueued(ctx)
+	}
+	first := worker.JobStarted(ctx)
+	second := worker.JobStarted(ctx)
+	worker.JobStarted(ctx)
+	clock.advance(time.Second)
+	worker.JobFinished(ctx, first, nil)
+	clock.advance(2 * time.Second)
+	worker.JobFinished(ctx, second, errors.New("smtp timeout"))
+
+	metrics := collect(t, reader)
+	sums := map[string]int64{workerJobsEnqueuedMetric: 3, workerJobsActiveMetric: 1, workerJobsFailedMetric: 1}
+	for name, want := range sums {
+		sum, ok := metrics[name].Data.(metricdata.Sum[int64])
+		if !ok || len(sum.DataPoints) != 1 {
+			t.Fatalf("%s = %+v, want one point", name, metrics[name].Data)
+		}
+		point := sum.DataPoints[0]
+		if point.Value != want {
+			t.Errorf("%s = %d, want %d", name, point.Value, want)
+		}
+		if pool, _ := point.Attributes.Value(WorkerPoolKey); pool.AsString() != "emails" {
+			t.Errorf("%s %s = %q, want emails", name, WorkerPoolKey, pool.AsString())
+		}
+	}
+
+	durations := make(map[bool]float64)
+	for _, point := range metrics[workerJobDurationMetric].Data.(metricdata.Histogram[float64]).DataPoints {
+		failed, _ := point.Attributes.Value("error")
+		if point.Count != 1 {
+			t.Errorf("error=%v recorded %d jobs, want 1", failed.AsBool(), point.Count)
+		}
+		durations[failed.AsBool()] = point.Sum
+	}
+	if durations[false] != 1 || durations[true] != 3 {
+		t.Errorf("durations by error = %v, want 1s ok and 3s failed", durations)
+	}
+
+	depth := metrics[workerQueueDepthMetric].Data.(metricdata.Gauge[float64])
+	if len(depth.DataPoints) != 1 || depth.DataPoints[0].Value != 5 {
+		t.Errorf("%s = %+v, want 5", workerQueueDepthMetric, depth.DataPoints)
+	}
+}